@@ -18,17 +18,21 @@ package aic_package
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"image"
-	"image/color/palette"
+	"image/color"
 	"image/draw"
 	"image/gif"
+	"io/ioutil"
+	"math"
 	"os"
 	"runtime"
 	"strconv"
-	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
+
+	"golang.org/x/term"
 
 	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
 )
@@ -38,6 +42,22 @@ type GifFrame struct {
 	delay        int
 }
 
+/*
+Decoded animation, whose frames are drawn one at a time so that they don't all have to be kept in memory.
+Delays are in hundredths of a second, and loopCount follows the LoopCount field of gif.GIF
+*/
+type animation struct {
+	frameCount int
+	delays     []int
+	loopCount  int
+
+	// Returns a function that draws the next frame each time it's called, returning a copy of the full frame if
+	// keep is true and nil otherwise, or an error if the frame can't be decoded. Frames are drawn over each other,
+	// so the function has to be called for every frame in order, though it may skip drawing frames that later
+	// ones don't depend on
+	newCompositor func() func(keep bool) (image.Image, error)
+}
+
 /*
 This function grabs each image frame from passed gif and turns it into ascii art. If SaveGifPath flag is passed,
 it'll turn each ascii art into an image instance of the same dimensions as the original gif and save them
@@ -48,17 +68,22 @@ Multi-threading has been implemented in multiple places due to long execution ti
 func pathIsGif(gifPath, urlImgName string, pathIsURl bool, urlImgBytes, pipedInputBytes []byte, localGif *os.File) error {
 
 	var (
-		originalGif *gif.GIF
-		err         error
+		gifBytes []byte
+		err      error
 	)
 
 	if gifPath == "-" {
-		originalGif, err = gif.DecodeAll(bytes.NewReader(pipedInputBytes))
+		gifBytes = pipedInputBytes
 	} else if pathIsURl {
-		originalGif, err = gif.DecodeAll(bytes.NewReader(urlImgBytes))
+		gifBytes = urlImgBytes
 	} else {
-		originalGif, err = gif.DecodeAll(localGif)
+		gifBytes, err = ioutil.ReadAll(localGif)
+		if err != nil {
+			return fmt.Errorf("can't read %v: %v", gifPath, err)
+		}
 	}
+
+	anim, err := splitGifFrames(gifBytes)
 	if err != nil {
 		if gifPath == "-" {
 			return fmt.Errorf("can't decode piped input: %v", err)
@@ -67,87 +92,88 @@ func pathIsGif(gifPath, urlImgName string, pathIsURl bool, urlImgBytes, pipedInp
 		}
 	}
 
-	var (
-		asciiArtSet    = make([]string, len(originalGif.Image))
-		gifFramesSlice = make([]GifFrame, len(originalGif.Image))
-
-		counter             = 0
-		concurrentProcesses = 0
-		wg                  sync.WaitGroup
-		hostCpuCount        = runtime.NumCPU()
-	)
-
-	fmt.Printf("Generating ascii art... 0%%\r")
+	return convertAnimation(anim, gifPath, urlImgName)
+}
 
-	// Get first frame of gif and its dimensions
-	firstGifFrame := originalGif.Image[0].SubImage(originalGif.Image[0].Rect)
-	firstGifFrameWidth := firstGifFrame.Bounds().Dx()
-	firstGifFrameHeight := firstGifFrame.Bounds().Dy()
+/*
+Turns the frames of the passed animation into ascii art, then saves them as a gif if SaveGifPath flag is passed
+and plays them on the terminal unless OnlySave flag is passed. Frames are drawn one at a time, and the ascii art of
+all of them is kept in memory unless StreamGif flag is passed
+*/
+func convertAnimation(anim animation, inputPath, urlImgName string) error {
 
-	// Multi-threaded loop to decrease execution time
-	for i, frame := range originalGif.Image {
+	if anim.frameCount == 0 {
+		return fmt.Errorf("animation has no frames")
+	}
 
-		wg.Add(1)
-		concurrentProcesses++
+	// Checked before conversion, since keys can't be read while stdin is piped
+	if interactive && !onlySave && !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("interactive playback requires stdin to be a terminal")
+	}
 
-		go func(i int, frame *image.Paletted) {
+	frameIndices, frameDelays, err := selectGifFrames(anim.frameCount, anim.delays)
+	if err != nil {
+		return err
+	}
 
-			frameImage := frame.SubImage(frame.Rect)
+	loopCount := anim.loopCount
+	if gifLoopCount != 0 {
+		loopCount = getGifLoopCount(gifLoopCount)
+	}
 
-			// If a frame is found that is smaller than the first frame, then this gif contains smaller subimages that are
-			// positioned inside the original gif. This behavior isn't supported by this app
-			if firstGifFrameWidth != frameImage.Bounds().Dx() || firstGifFrameHeight != frameImage.Bounds().Dy() {
-				if urlImgName == "" {
-					fmt.Printf("Error: " + gifPath + " contains subimages smaller than default width and height\n\nProcess aborted because ascii-image-converter doesn't support subimage placement and transparency in GIFs\n\n")
-				} else {
-					fmt.Printf("Error: " + urlImgName + " contains subimages smaller than default width and height\n\nProcess aborted because ascii-image-converter doesn't support subimage placement and transparency in GIFs\n\n")
-				}
-				os.Exit(0)
-			}
+	// The first frame decides the crop rectangle and the dimensions of the saved gif
+	firstGifFrames, err := collectGifFrames(anim, frameIndices[:1])
+	if err != nil {
+		return err
+	}
+	firstGifFrame := firstGifFrames[0]
 
-			var imgSet [][]imgManip.AsciiPixel
+	// The same area is cropped from every frame
+	cropRect, err := imgManip.GetCropRectangle(rotateImage(firstGifFrame), crop, cropPercent, autoTrim)
+	if err != nil {
+		return err
+	}
 
-			imgSet, err = imgManip.ConvertToAsciiPixels(frameImage, dimensions, width, height, flipX, flipY, full, braille, dither)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(0)
-			}
+	if streamGif {
+		return streamAnimation(anim, frameIndices, frameDelays, loopCount, firstGifFrame, cropRect, inputPath, urlImgName)
+	}
 
-			var asciiCharSet [][]imgManip.AsciiChar
-			if braille {
-				asciiCharSet, err = imgManip.ConvertToBrailleChars(imgSet, negative, colored, grayscale, colorBg, fontColor, threshold)
-			} else {
-				asciiCharSet, err = imgManip.ConvertToAsciiChars(imgSet, negative, colored, grayscale, complex, colorBg, customMap, fontColor)
-			}
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(0)
-			}
+	// Share one histogram between all frames, so that equalization doesn't flicker between them
+	if adjustments.Equalization != "" && equalizeAcrossFrames {
+		histograms, err := computeSharedHistograms(anim, frameIndices, cropRect)
+		if err != nil {
+			return err
+		}
+		adjustments.EqualizationHistograms = histograms
+	}
 
-			gifFramesSlice[i].asciiCharSet = asciiCharSet
-			gifFramesSlice[i].delay = originalGif.Delay[i]
+	fmt.Printf("Generating ascii art... 0%%\r")
 
-			ascii := flattenAscii(asciiCharSet, colored || grayscale, false)
+	// Frames are drawn as the workers need them, so only their ascii art is kept
+	gifFramesSlice := make([]GifFrame, len(frameIndices))
 
-			asciiArtSet[i] = strings.Join(ascii, "\n")
+	err = streamAnimationFrames(anim, frameIndices, cropRect, nil, func(frame streamedFrame) error {
+		gifFramesSlice[frame.index].asciiCharSet = frame.asciiSet
+		gifFramesSlice[frame.index].delay = frameDelays[frame.index]
 
-			counter++
-			percentage := int((float64(counter) / float64(len(originalGif.Image))) * 100)
-			fmt.Printf("Generating ascii art... " + strconv.Itoa(percentage) + "%%\r")
+		percentage := int((float64(frame.index+1) / float64(len(gifFramesSlice))) * 100)
+		fmt.Printf("Generating ascii art... " + strconv.Itoa(percentage) + "%%\r")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-			wg.Done()
+	fmt.Printf("                              \r")
 
-		}(i, frame)
+	// Reversing and ping-ponging only reorder converted frames, so repeated frames aren't converted again
+	frameOrder := getGifFrameOrder(len(gifFramesSlice))
+	orderedFrames := make([]GifFrame, len(frameOrder))
 
-		// Limit concurrent processes according to host's CPU count to avoid overwhelming memory
-		if concurrentProcesses == hostCpuCount {
-			wg.Wait()
-			concurrentProcesses = 0
-		}
+	for i, index := range frameOrder {
+		orderedFrames[i] = gifFramesSlice[index]
 	}
-
-	wg.Wait()
-	fmt.Printf("                              \r")
+	gifFramesSlice = orderedFrames
 
 	// Save ascii art as .gif file before displaying it, if --save-gif flag is passed
 	if saveGifPath != "" {
@@ -155,7 +181,7 @@ func pathIsGif(gifPath, urlImgName string, pathIsURl bool, urlImgBytes, pipedInp
 		// Storing save path string before executing ascii art to gif conversion
 		// This is done to avoid wasting time for invalid path errors
 
-		saveFileName, err := createSaveFileName(gifPath, urlImgName, "-ascii-art.gif")
+		saveFileName, err := createSaveFileName(inputPath, urlImgName, "-ascii-art.gif")
 		if err != nil {
 			return err
 		}
@@ -167,110 +193,515 @@ func pathIsGif(gifPath, urlImgName string, pathIsURl bool, urlImgBytes, pipedInp
 
 		// Initializing some constants for gif. Done outside loop to save execution
 		outGif := &gif.GIF{
-			LoopCount: originalGif.LoopCount,
-		}
-		opts := gif.Options{
-			NumColors: 256,
-			Drawer:    draw.FloydSteinberg,
+			LoopCount: loopCount,
 		}
 
+		paletteColors, drawer := getGifQuantization()
+
 		// Initializing slices for each ascii art image as well as delay
 		var (
 			palettedImageSlice = make([]*image.Paletted, len(gifFramesSlice))
 			delaySlice         = make([]int, len(gifFramesSlice))
+
+			// Rendered frames are only kept when a palette is generated from all of them
+			renderedImageSlice = make([]image.Image, len(gifFramesSlice))
 		)
 
-		// For the purpose of displaying counter and limiting concurrent processes
-		counter = 0
-		concurrentProcesses = 0
+		// For the purpose of displaying counter
+		var counter int64
 
 		fmt.Printf("Saving gif... 0%%\r")
 
-		// Multi-threaded loop to decrease execution time
+		runOnWorkers(len(gifFramesSlice), func(i int) {
+
+			tempImg, err := createGifFrameToSave(
+				gifFramesSlice[i].asciiCharSet,
+				firstGifFrame,
+				colored || grayscale,
+			)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(0)
+			}
+
+			if globalGifPalette {
+				renderedImageSlice[i] = tempImg
+			} else {
+				framePalette := imgManip.GeneratePalette([]image.Image{tempImg}, gifPalette, paletteColors)
+				palettedImageSlice[i] = quantizeFrame(tempImg, framePalette, drawer)
+			}
+			delaySlice[i] = gifFramesSlice[i].delay
+
+			percentage := int((float64(atomic.AddInt64(&counter, 1)) / float64(len(gifFramesSlice))) * 100)
+			fmt.Printf("Saving gif... " + strconv.Itoa(percentage) + "%%\r")
+		})
+
+		if globalGifPalette {
+			sharedPalette := imgManip.GeneratePalette(renderedImageSlice, gifPalette, paletteColors)
+
+			for i, tempImg := range renderedImageSlice {
+				palettedImageSlice[i] = quantizeFrame(tempImg, sharedPalette, drawer)
+			}
+		}
+
+		// Only the changes between frames are stored, which keeps ascii art gifs small
+		outGif.Image, outGif.Delay, outGif.Disposal = optimizeGifFrames(palettedImageSlice, delaySlice)
+
+		gifFile, err := os.OpenFile(fullPathName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return fmt.Errorf("can't save file: %v", err)
+		}
+		defer gifFile.Close()
+
+		if err := gif.EncodeAll(gifFile, outGif); err != nil {
+			return fmt.Errorf("can't save file: %v", err)
+		}
+
+		fmt.Printf("                     \r")
+
+		fmt.Println("Saved " + fullPathName)
+	}
+
+	// Display the gif
+	if !onlySave {
+		asciiSets := make([][][]imgManip.AsciiChar, len(gifFramesSlice))
+		delays := make([]int, len(gifFramesSlice))
 		for i, gifFrame := range gifFramesSlice {
+			asciiSets[i] = gifFrame.asciiCharSet
+			delays[i] = gifFrame.delay
+		}
 
-			wg.Add(1)
-			concurrentProcesses++
+		if !interactive {
+			playAsciiFrames(asciiSets, delays, getTerminalPlayCount(loopCount), colored || grayscale)
+			return nil
+		}
 
-			go func(i int, gifFrame GifFrame) {
+		// Frames are saved as .png or .txt files according to the set save paths, or in the current directory if
+		// neither is set. Emoji frames are saved as .txt files there, since the bundled fonts have no emoji glyphs
+		saveFrame := func(index int) error {
+			label := fmt.Sprintf("-frame-%d-ascii-art", index+1)
+			asciiSet := gifFramesSlice[index].asciiCharSet
 
-				img := originalGif.Image[i].SubImage(originalGif.Image[i].Rect)
+			if saveImagePath == "" && saveTxtPath == "" {
+				if emoji && !braille && fontPath == "" {
+					return saveAsciiArt(asciiSet, inputPath, ".", urlImgName, label+".txt", false)
+				}
+				return createImageToSave(asciiSet, colored || grayscale, ".", inputPath, urlImgName, label+".png", false)
+			}
 
-				tempImg, err := createGifFrameToSave(
-					gifFrame.asciiCharSet,
-					img,
-					colored || grayscale,
-				)
-				if err != nil {
-					fmt.Printf("Error: %v\n", err)
-					os.Exit(0)
+			if saveImagePath != "" {
+				if err := createImageToSave(asciiSet, colored || grayscale, saveImagePath, inputPath, urlImgName, label+".png", false); err != nil {
+					return err
 				}
+			}
 
-				// Following code takes tempImg as image.Image instance and converts it into *image.Paletted instance
-				b := tempImg.Bounds()
+			if saveTxtPath != "" {
+				return saveAsciiArt(asciiSet, inputPath, saveTxtPath, urlImgName, label+".txt", false)
+			}
+
+			return nil
+		}
 
-				palettedImg := image.NewPaletted(b, palette.Plan9[:opts.NumColors])
+		return playAsciiFramesInteractively(asciiSets, delays, getTerminalPlayCount(loopCount), colored || grayscale, saveFrame)
+	}
 
-				opts.Drawer.Draw(palettedImg, b, tempImg, image.Point{})
+	return nil
+}
 
-				palettedImageSlice[i] = palettedImg
-				delaySlice[i] = gifFrame.delay
+// Calls task with each index from 0 to count-1 on a fixed pool of workers, one per CPU, and returns once all calls
+// are done. Workers take the next index as soon as they're free, so one slow call doesn't hold the others back
+func runOnWorkers(count int, task func(i int)) {
 
-				counter++
-				percentage := int((float64(counter) / float64(len(gifFramesSlice))) * 100)
-				fmt.Printf("Saving gif... " + strconv.Itoa(percentage) + "%%\r")
+	indices := make(chan int)
+	var wg sync.WaitGroup
 
-				wg.Done()
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
 
-			}(i, gifFrame)
+		go func() {
+			defer wg.Done()
 
-			// Limit concurrent processes according to host's CPU count to avoid overwhelming memory
-			if concurrentProcesses == hostCpuCount {
-				wg.Wait()
-				concurrentProcesses = 0
+			for i := range indices {
+				task(i)
 			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indices <- i
+	}
+	close(indices)
+
+	wg.Wait()
+}
+
+// Returns the frames of the passed animation at the passed indices, which must be in ascending order
+func collectGifFrames(anim animation, indices []int) ([]image.Image, error) {
+
+	nextFrame := anim.newCompositor()
+	frames := make([]image.Image, 0, len(indices))
 
+	for i := 0; len(frames) < len(indices); i++ {
+		frame, err := nextFrame(i == indices[len(frames)])
+		if err != nil {
+			return nil, err
+		}
+		if frame != nil {
+			frames = append(frames, frame)
 		}
+	}
+
+	return frames, nil
+}
+
+/*
+Draws the frames of the passed animation at the passed indices and computes the equalization histograms of each
+from the image that's converted into ascii art, adding them up into the ones shared by all frames
+*/
+func computeSharedHistograms(anim animation, frameIndices []int, cropRect image.Rectangle) (imgManip.TileHistograms, error) {
 
-		wg.Wait()
+	var histograms imgManip.TileHistograms
 
-		outGif.Image = palettedImageSlice
-		outGif.Delay = delaySlice
+	options := getConversionOptions()
+	nextFrame := anim.newCompositor()
 
-		gifFile, err := os.OpenFile(fullPathName, os.O_WRONLY|os.O_CREATE, 0666)
+	for i, selected := 0, 0; selected < len(frameIndices); i++ {
+		frameImage, err := nextFrame(i == frameIndices[selected])
 		if err != nil {
-			return fmt.Errorf("can't save file: %v", err)
+			return nil, err
+		}
+		if frameImage == nil {
+			continue
 		}
-		defer gifFile.Close()
 
-		gif.EncodeAll(gifFile, outGif)
+		frameHistograms, err := imgManip.ComputeEqualizationHistograms(prepareInputImage(rotateImage(frameImage), cropRect), options)
+		if err != nil {
+			return nil, err
+		}
 
-		fmt.Printf("                     \r")
+		if selected == 0 {
+			histograms = frameHistograms
+		} else {
+			histograms.Add(frameHistograms)
+		}
+		selected++
+	}
 
-		fmt.Println("Saved " + fullPathName)
+	return histograms, nil
+}
+
+/*
+Returns the number of colors of palettes generated for saved gifs, along with the drawer that maps frames to them.
+Generated palettes leave room for the transparent color used by optimizeGifFrames()
+*/
+func getGifQuantization() (int, draw.Drawer) {
+
+	paletteColors := 256
+	if gifPalette == "median-cut" || gifPalette == "octree" {
+		paletteColors--
 	}
 
-	// Display the gif
-	if !onlySave {
-		loopCount := 0
-		for {
-			for i, asciiFrame := range asciiArtSet {
-				clearScreen()
-				fmt.Println(asciiFrame)
-				time.Sleep(time.Duration((time.Second * time.Duration(originalGif.Delay[i])) / 100))
+	// Without dithering, each pixel simply takes its nearest palette color, which keeps character edges clean
+	if noGifDither {
+		return paletteColors, draw.Src
+	}
+
+	return paletteColors, draw.FloydSteinberg
+}
+
+/*
+Returns the indices of the frames within the Flags.GifFrames range, and every Nth of them for Flags.GifFrameStep,
+along with their delays. The delays of skipped frames are added to the kept frame before them, so that the
+animation keeps its timing. Delays are then scaled by Flags.GifSpeed
+*/
+func selectGifFrames(frameCount int, delays []int) ([]int, []int, error) {
+
+	start, end := 0, frameCount-1
+	if gifFrameRange != nil {
+		start = gifFrameRange[0]
+		if gifFrameRange[1] < end {
+			end = gifFrameRange[1]
+		}
+	}
+
+	if start > end {
+		return nil, nil, fmt.Errorf("frame range starts after the gif's last frame %v", frameCount-1)
+	}
+
+	step := 1
+	if gifFrameStep > 1 {
+		step = gifFrameStep
+	}
+
+	var (
+		selectedIndices []int
+		selectedDelays  []int
+	)
+
+	for i := start; i <= end; i++ {
+		var delay int
+		if i < len(delays) {
+			delay = delays[i]
+		}
+
+		if (i-start)%step == 0 {
+			selectedIndices = append(selectedIndices, i)
+			selectedDelays = append(selectedDelays, delay)
+		} else {
+			selectedDelays[len(selectedDelays)-1] += delay
+		}
+	}
+
+	if gifSpeed > 0 && gifSpeed != 1 {
+		for i, delay := range selectedDelays {
+			scaled := int(math.Round(float64(delay) / gifSpeed))
+
+			// A delay of 0 is played at a default speed by most viewers, so it isn't reached by speeding up
+			if delay > 0 && scaled < 1 {
+				scaled = 1
 			}
+			selectedDelays[i] = scaled
+		}
+	}
+
+	return selectedIndices, selectedDelays, nil
+}
+
+// Returns the order in which the selected frames are played, according to Flags.GifReverse and Flags.GifPingPong
+func getGifFrameOrder(frameCount int) []int {
+
+	order := make([]int, frameCount)
+	for i := range order {
+		if gifReverse {
+			order[i] = frameCount - 1 - i
+		} else {
+			order[i] = i
+		}
+	}
+
+	// Play back through the frames without repeating the first and last ones, which are shown at each turn
+	if gifPingPong {
+		for i := len(order) - 2; i > 0; i-- {
+			order = append(order, order[i])
+		}
+	}
+
+	return order
+}
+
+/*
+Converts Flags.GifLoopCount into the LoopCount field of gif.GIF, where 0 loops forever, -1 plays once and any
+other value is the number of times the animation is repeated after playing once
+*/
+func getGifLoopCount(plays int) int {
+	switch {
+	case plays < 0:
+		return 0
+	case plays == 1:
+		return -1
+	default:
+		return plays - 1
+	}
+}
+
+// Returns the number of times a gif with the passed LoopCount field is played, where 0 means forever
+func getGifPlayCount(loopCount int) int {
+	switch {
+	case loopCount == 0:
+		return 0
+	case loopCount < 0:
+		return 1
+	default:
+		return loopCount + 1
+	}
+}
+
+// Returns the number of times a gif is played in the terminal, where 0 means forever. Unlike saved gifs, the
+// terminal loops forever regardless of the gif's own loop count, unless Flags.GifLoopCount is set
+func getTerminalPlayCount(loopCount int) int {
+	if gifLoopCount == 0 {
+		return 0
+	}
+	return getGifPlayCount(loopCount)
+}
 
-			// If gif is infinite loop
-			if originalGif.LoopCount == 0 {
-				continue
+// Converts the passed image into a *image.Paletted instance of the passed palette, using drawer for picking colors
+func quantizeFrame(img image.Image, framePalette color.Palette, drawer draw.Drawer) *image.Paletted {
+
+	b := img.Bounds()
+	palettedImg := image.NewPaletted(b, framePalette)
+
+	drawer.Draw(palettedImg, b, img, b.Min)
+
+	return palettedImg
+}
+
+/*
+Splits the passed gif into its frames without decoding their image data. Each frame is rebuilt as a gif of its own
+out of the gif's header and the frame's blocks, and is only decoded by image/gif while it's drawn, so that frames
+aren't all kept in memory at once. Delays, disposal methods and the loop count follow image/gif's decoder
+*/
+func splitGifFrames(data []byte) (animation, error) {
+
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return animation{}, fmt.Errorf("gif: can't recognize format")
+	}
+
+	truncated := fmt.Errorf("gif: file is truncated")
+
+	// Skips the data sub-blocks starting at offset and returns the offset after their terminator
+	skipSubBlocks := func(offset int) (int, error) {
+		for offset < len(data) {
+			size := int(data[offset])
+			offset += 1 + size
+			if size == 0 {
+				return offset, nil
+			}
+		}
+		return 0, truncated
+	}
+
+	width := int(binary.LittleEndian.Uint16(data[6:]))
+	height := int(binary.LittleEndian.Uint16(data[8:]))
+
+	headerEnd := 13
+	if fields := data[10]; fields&0x80 != 0 {
+		headerEnd += 3 * (1 << (1 + uint(fields&0x07)))
+	}
+	if headerEnd > len(data) {
+		return animation{}, truncated
+	}
+
+	var (
+		frames    []encodedFrame
+		blocks    [][]byte
+		delays    []int
+		loopCount = -1
+
+		// The graphic control extension only applies to the next frame, except for its disposal method, which
+		// image/gif keeps for the frames after it
+		control  []byte
+		delay    int
+		disposal byte
+	)
+
+	for offset := headerEnd; ; {
+		if offset >= len(data) {
+			return animation{}, truncated
+		}
+
+		start := offset
+		blockType := data[offset]
+
+		if blockType == 0x3b {
+			break
+		}
+
+		switch blockType {
+		case 0x21:
+			if offset+2 >= len(data) {
+				return animation{}, truncated
 			}
+			label := data[offset+1]
+			firstBlock := data[offset+2:]
 
-			loopCount++
-			if loopCount == originalGif.LoopCount {
-				break
+			end, err := skipSubBlocks(offset + 2)
+			if err != nil {
+				return animation{}, err
 			}
+			offset = end
+
+			switch {
+			case label == 0xf9 && firstBlock[0] >= 4:
+				control = data[start:end]
+				disposal = (firstBlock[1] & 0x1c) >> 2
+				delay = int(binary.LittleEndian.Uint16(firstBlock[2:]))
+
+			case label == 0xff && firstBlock[0] == 11 && string(firstBlock[1:12]) == "NETSCAPE2.0":
+				if loop := firstBlock[12:]; len(loop) >= 4 && loop[0] == 3 && loop[1] == 1 {
+					loopCount = int(binary.LittleEndian.Uint16(loop[2:]))
+				}
+			}
+
+		case 0x2c:
+			if offset+11 > len(data) {
+				return animation{}, truncated
+			}
+			left := int(binary.LittleEndian.Uint16(data[offset+1:]))
+			top := int(binary.LittleEndian.Uint16(data[offset+3:]))
+			frameWidth := int(binary.LittleEndian.Uint16(data[offset+5:]))
+			frameHeight := int(binary.LittleEndian.Uint16(data[offset+7:]))
+
+			offset += 10
+			if fields := data[offset-1]; fields&0x80 != 0 {
+				offset += 3 * (1 << (1 + uint(fields&0x07)))
+			}
+
+			// The minimum code size comes before the image data
+			end, err := skipSubBlocks(offset + 1)
+			if err != nil {
+				return animation{}, err
+			}
+			offset = end
+
+			block := append(append([]byte(nil), control...), data[start:end]...)
+
+			frames = append(frames, encodedFrame{
+				rect:     image.Rect(left, top, left+frameWidth, top+frameHeight),
+				blend:    true,
+				disposal: disposal,
+			})
+			blocks = append(blocks, block)
+			delays = append(delays, delay)
+
+			control = nil
+			delay = 0
+
+		default:
+			return animation{}, fmt.Errorf("gif: unknown block type: 0x%.2x", blockType)
 		}
 	}
 
-	return nil
+	if len(frames) == 0 {
+		return animation{}, fmt.Errorf("gif: missing image data")
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+
+	// Some gifs don't declare their size, so it's taken from the frames instead
+	if bounds.Empty() {
+		for _, frame := range frames {
+			bounds = bounds.Union(frame.rect)
+		}
+	}
+
+	header := append([]byte(nil), data[:headerEnd]...)
+	binary.LittleEndian.PutUint16(header[6:], uint16(bounds.Dx()))
+	binary.LittleEndian.PutUint16(header[8:], uint16(bounds.Dy()))
+
+	for i := range frames {
+		if !frames[i].rect.In(bounds) {
+			return animation{}, fmt.Errorf("gif: frame bounds larger than image bounds")
+		}
+
+		frameFile := make([]byte, 0, len(header)+len(blocks[i])+1)
+		frameFile = append(append(append(frameFile, header...), blocks[i]...), 0x3b)
+
+		frames[i].decode = func() (image.Image, error) {
+			return gif.Decode(bytes.NewReader(frameFile))
+		}
+	}
+
+	anim := animation{
+		frameCount: len(frames),
+		delays:     delays,
+		loopCount:  loopCount,
+
+		// Frames of optimized gifs only hold the area that changed, so they're drawn over each other first
+		newCompositor: func() func(keep bool) (image.Image, error) {
+			return newEncodedFrameCompositor(bounds, frames)
+		},
+	}
+
+	return anim, nil
 }
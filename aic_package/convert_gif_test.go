@@ -0,0 +1,277 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// Builds a gif out of frames filled with a single color, with transparency as the palette's first color
+func buildGif(t *testing.T, width, height, loopCount int, frames []testFrame) []byte {
+	t.Helper()
+
+	palette := color.Palette{color.NRGBA{}, testRed, testGreen, testBlue}
+	g := &gif.GIF{
+		LoopCount: loopCount,
+		Config:    image.Config{ColorModel: palette, Width: width, Height: height},
+	}
+
+	for _, frame := range frames {
+		img := image.NewPaletted(frame.rect, palette)
+		index := uint8(palette.Index(frame.fill))
+		for i := range img.Pix {
+			img.Pix[i] = index
+		}
+
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, frame.delay)
+		g.Disposal = append(g.Disposal, frame.dispose)
+	}
+
+	var file bytes.Buffer
+	if err := gif.EncodeAll(&file, g); err != nil {
+		t.Fatal(err)
+	}
+	return file.Bytes()
+}
+
+func TestSplitGifFrames(t *testing.T) {
+
+	data := buildGif(t, 8, 8, 2, []testFrame{
+		{rect: image.Rect(0, 0, 8, 8), fill: testRed, delay: 10},
+		{rect: image.Rect(2, 2, 6, 6), fill: testGreen, delay: 20, dispose: gif.DisposalBackground},
+		{rect: image.Rect(4, 4, 8, 8), fill: testBlue, delay: 5, dispose: gif.DisposalPrevious},
+		{rect: image.Rect(0, 0, 2, 2), fill: color.NRGBA{}, delay: 0, dispose: gif.DisposalNone},
+	})
+
+	anim, err := splitGifFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if anim.frameCount != 4 {
+		t.Fatalf("frameCount = %d, want 4", anim.frameCount)
+	}
+	if want := []int{10, 20, 5, 0}; !equalInts(anim.delays, want) {
+		t.Errorf("delays = %v, want %v", anim.delays, want)
+	}
+	if anim.loopCount != 2 {
+		t.Errorf("loopCount = %d, want 2", anim.loopCount)
+	}
+
+	// Frame 1 is cleared to transparency after it's shown, frame 2 is restored to what was under it and the
+	// transparent pixels of frame 3 let the canvas show through
+	checkColors(t, "center", colorsAt(t, anim, image.Pt(3, 3)), []color.NRGBA{testRed, testGreen, {}, {}})
+	checkColors(t, "corner", colorsAt(t, anim, image.Pt(5, 5)), []color.NRGBA{testRed, testGreen, testBlue, {}})
+	checkColors(t, "origin", colorsAt(t, anim, image.Pt(0, 0)), []color.NRGBA{testRed, testRed, testRed, testRed})
+}
+
+func TestSplitGifFramesLoopCount(t *testing.T) {
+
+	// Without a NETSCAPE2.0 extension, gifs play once like image/gif reports them
+	data := buildGif(t, 2, 2, -1, []testFrame{
+		{rect: image.Rect(0, 0, 2, 2), fill: testRed},
+		{rect: image.Rect(0, 0, 2, 2), fill: testGreen},
+	})
+
+	anim, err := splitGifFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim.loopCount != -1 {
+		t.Errorf("loopCount = %d, want -1", anim.loopCount)
+	}
+	checkColors(t, "frames", colorsAt(t, anim, image.Pt(1, 1)), []color.NRGBA{testRed, testGreen})
+}
+
+func TestSplitGifFramesErrors(t *testing.T) {
+
+	data := buildGif(t, 4, 4, 0, []testFrame{
+		{rect: image.Rect(0, 0, 4, 4), fill: testRed},
+		{rect: image.Rect(0, 0, 4, 4), fill: testGreen},
+	})
+
+	if _, err := splitGifFrames(data[:len(data)-5]); err == nil {
+		t.Error("splitGifFrames() of a truncated file didn't fail")
+	}
+
+	// Header and trailer without any frames
+	headerEnd := 13 + 3*(1<<(1+int(data[10]&0x07)))
+	empty := append(append([]byte(nil), data[:headerEnd]...), 0x3b)
+	if _, err := splitGifFrames(empty); err == nil {
+		t.Error("splitGifFrames() of a gif without frames didn't fail")
+	}
+
+	if _, err := splitGifFrames([]byte("not a gif")); err == nil {
+		t.Error("splitGifFrames() of a non-gif didn't fail")
+	}
+}
+
+func TestSplitGifFramesCorruptFrame(t *testing.T) {
+
+	data := buildGif(t, 4, 4, 0, []testFrame{
+		{rect: image.Rect(0, 0, 4, 4), fill: testRed},
+		{rect: image.Rect(0, 0, 4, 4), fill: testGreen},
+	})
+
+	// Frames are only decoded while they're drawn, so an invalid code size in the second one's image data
+	// is reported by the compositor rather than by splitGifFrames()
+	control := bytes.LastIndex(data, []byte{0x21, 0xf9, 0x04})
+	data[control+8+10] = 12
+
+	anim, err := splitGifFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nextFrame := anim.newCompositor()
+	if _, err := nextFrame(true); err != nil {
+		t.Fatalf("first frame: %v", err)
+	}
+	if _, err := nextFrame(true); err == nil {
+		t.Error("drawing a corrupt frame didn't fail")
+	}
+}
+
+func TestSelectGifFrames(t *testing.T) {
+
+	defer func() {
+		gifFrameRange, gifFrameStep, gifSpeed = nil, 0, 0
+	}()
+
+	delays := []int{10, 20, 30, 40, 50, 60}
+
+	tests := []struct {
+		name        string
+		frameRange  []int
+		step        int
+		speed       float64
+		wantIndices []int
+		wantDelays  []int
+	}{
+		{"all frames", nil, 0, 0, []int{0, 1, 2, 3, 4, 5}, delays},
+		{"range", []int{1, 3}, 0, 0, []int{1, 2, 3}, []int{20, 30, 40}},
+		{"range past the end", []int{4, 100}, 0, 0, []int{4, 5}, []int{50, 60}},
+		// Skipped frames add their delays to the kept frame before them
+		{"step", nil, 2, 0, []int{0, 2, 4}, []int{30, 70, 110}},
+		{"step within range", []int{1, 4}, 3, 0, []int{1, 4}, []int{90, 50}},
+		{"speed", nil, 0, 2, []int{0, 1, 2, 3, 4, 5}, []int{5, 10, 15, 20, 25, 30}},
+		{"slower speed", []int{0, 1}, 0, 0.5, []int{0, 1}, []int{20, 40}},
+		// Sped up delays never reach 0, which viewers play at a default speed
+		{"high speed", []int{0, 1}, 0, 100, []int{0, 1}, []int{1, 1}},
+	}
+
+	for _, test := range tests {
+		gifFrameRange, gifFrameStep, gifSpeed = test.frameRange, test.step, test.speed
+
+		indices, selectedDelays, err := selectGifFrames(len(delays), delays)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !equalInts(indices, test.wantIndices) || !equalInts(selectedDelays, test.wantDelays) {
+			t.Errorf("%v: got %v %v, want %v %v", test.name, indices, selectedDelays, test.wantIndices, test.wantDelays)
+		}
+	}
+
+	// Frames without a delay count as 0
+	gifFrameRange, gifFrameStep, gifSpeed = nil, 2, 0
+	if _, selectedDelays, _ := selectGifFrames(3, []int{10}); !equalInts(selectedDelays, []int{10, 0}) {
+		t.Errorf("delays of frames without one = %v, want [10 0]", selectedDelays)
+	}
+
+	gifFrameRange, gifFrameStep = []int{6, 8}, 0
+	if _, _, err := selectGifFrames(len(delays), delays); err == nil {
+		t.Error("selectGifFrames() with a range after the last frame didn't fail")
+	}
+}
+
+func TestGetGifFrameOrder(t *testing.T) {
+
+	defer func() { gifReverse, gifPingPong = false, false }()
+
+	tests := []struct {
+		reverse, pingPong bool
+		frameCount        int
+		want              []int
+	}{
+		{false, false, 4, []int{0, 1, 2, 3}},
+		{true, false, 4, []int{3, 2, 1, 0}},
+		// The first and last frames aren't repeated at each turn
+		{false, true, 4, []int{0, 1, 2, 3, 2, 1}},
+		{true, true, 4, []int{3, 2, 1, 0, 1, 2}},
+		{false, true, 2, []int{0, 1}},
+		{false, true, 1, []int{0}},
+	}
+
+	for _, test := range tests {
+		gifReverse, gifPingPong = test.reverse, test.pingPong
+
+		if got := getGifFrameOrder(test.frameCount); !equalInts(got, test.want) {
+			t.Errorf("getGifFrameOrder(%d) with reverse %v and ping-pong %v = %v, want %v", test.frameCount, test.reverse, test.pingPong, got, test.want)
+		}
+	}
+}
+
+func TestGetGifLoopCount(t *testing.T) {
+
+	tests := []struct {
+		plays, loopCount int
+	}{
+		{-1, 0},
+		{1, -1},
+		{2, 1},
+		{5, 4},
+	}
+
+	for _, test := range tests {
+		if got := getGifLoopCount(test.plays); got != test.loopCount {
+			t.Errorf("getGifLoopCount(%d) = %d, want %d", test.plays, got, test.loopCount)
+		}
+
+		// Playing forever is 0 plays
+		wantPlays := test.plays
+		if wantPlays < 0 {
+			wantPlays = 0
+		}
+		if got := getGifPlayCount(test.loopCount); got != wantPlays {
+			t.Errorf("getGifPlayCount(%d) = %d, want %d", test.loopCount, got, wantPlays)
+		}
+	}
+}
+
+func TestGetTerminalPlayCount(t *testing.T) {
+
+	defer func() { gifLoopCount = 0 }()
+
+	// The terminal loops forever unless a loop count is passed, even for gifs that play once
+	gifLoopCount = 0
+	for _, loopCount := range []int{-1, 0, 3} {
+		if got := getTerminalPlayCount(loopCount); got != 0 {
+			t.Errorf("getTerminalPlayCount(%d) without --gif-loop = %d, want 0", loopCount, got)
+		}
+	}
+
+	gifLoopCount = 3
+	if got := getTerminalPlayCount(getGifLoopCount(3)); got != 3 {
+		t.Errorf("getTerminalPlayCount() with --gif-loop 3 = %d, want 3", got)
+	}
+}
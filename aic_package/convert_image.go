@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"io/ioutil"
 	"os"
 	"strings"
 
@@ -30,17 +31,23 @@ import (
 func pathIsImage(imagePath, urlImgName string, pathIsURl bool, urlImgBytes, pipedInputBytes []byte, localImg *os.File) (string, error) {
 
 	var (
-		imData image.Image
-		err    error
+		imData  image.Image
+		imBytes []byte
+		err     error
 	)
 
 	if imagePath == "-" {
-		imData, _, err = image.Decode(bytes.NewReader(pipedInputBytes))
+		imBytes = pipedInputBytes
 	} else if pathIsURl {
-		imData, _, err = image.Decode(bytes.NewReader(urlImgBytes))
+		imBytes = urlImgBytes
 	} else {
-		imData, _, err = image.Decode(localImg)
+		imBytes, err = ioutil.ReadAll(localImg)
+		if err != nil {
+			return "", fmt.Errorf("can't read %v: %v", imagePath, err)
+		}
 	}
+
+	imData, _, err = image.Decode(bytes.NewReader(imBytes))
 	if err != nil {
 		if imagePath == "-" {
 			return "", fmt.Errorf("can't decode piped input: %v", err)
@@ -49,18 +56,17 @@ func pathIsImage(imagePath, urlImgName string, pathIsURl bool, urlImgBytes, pipe
 		}
 	}
 
-	imgSet, err := imgManip.ConvertToAsciiPixels(imData, dimensions, width, height, flipX, flipY, full, braille, dither)
+	if !ignoreExifOrientation {
+		imData = imgManip.ApplyExifOrientation(imData, imgManip.GetExifOrientation(imBytes))
+	}
+	imData = rotateImage(imData)
+
+	cropRect, err := imgManip.GetCropRectangle(imData, crop, cropPercent, autoTrim)
 	if err != nil {
 		return "", err
 	}
 
-	var asciiSet [][]imgManip.AsciiChar
-
-	if braille {
-		asciiSet, err = imgManip.ConvertToBrailleChars(imgSet, negative, colored, grayscale, colorBg, fontColor, threshold)
-	} else {
-		asciiSet, err = imgManip.ConvertToAsciiChars(imgSet, negative, colored, grayscale, complex, colorBg, customMap, fontColor)
-	}
+	asciiSet, err := convertToAsciiSet(imData, cropRect)
 	if err != nil {
 		return "", err
 	}
@@ -73,6 +79,7 @@ func pathIsImage(imagePath, urlImgName string, pathIsURl bool, urlImgBytes, pipe
 			saveImagePath,
 			imagePath,
 			urlImgName,
+			"-ascii-art.png",
 			onlySave,
 		); err != nil {
 
@@ -87,6 +94,7 @@ func pathIsImage(imagePath, urlImgName string, pathIsURl bool, urlImgBytes, pipe
 			imagePath,
 			saveTxtPath,
 			urlImgName,
+			"-ascii-art.txt",
 			onlySave,
 		); err != nil {
 
@@ -17,11 +17,15 @@ limitations under the License.
 package aic_package
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
+	"strings"
 
 	// Image format initialization
 	_ "image/jpeg"
@@ -32,6 +36,7 @@ import (
 	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 
+	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
 	"github.com/golang/freetype/truetype"
 )
 
@@ -47,28 +52,76 @@ var pipedInputTypes = []string{
 // Can be sent directly to ConvertImage() for default ascii art
 func DefaultFlags() Flags {
 	return Flags{
-		Complex:             false,
-		Dimensions:          nil,
-		Width:               0,
-		Height:              0,
-		SaveTxtPath:         "",
-		SaveImagePath:       "",
-		SaveGifPath:         "",
-		Negative:            false,
-		Colored:             false,
-		CharBackgroundColor: false,
-		Grayscale:           false,
-		CustomMap:           "",
-		FlipX:               false,
-		FlipY:               false,
-		Full:                false,
-		FontFilePath:        "",
-		FontColor:           [3]int{255, 255, 255},
-		SaveBackgroundColor: [4]int{0, 0, 0, 100},
-		Braille:             false,
-		Threshold:           128,
-		Dither:              false,
-		OnlySave:            false,
+		Complex:               false,
+		Dimensions:            nil,
+		Width:                 0,
+		Height:                0,
+		SaveTxtPath:           "",
+		SaveImagePath:         "",
+		SaveGifPath:           "",
+		Negative:              false,
+		Colored:               false,
+		CharBackgroundColor:   false,
+		Grayscale:             false,
+		CustomMap:             "",
+		FillText:              "",
+		FillTextFilePath:      "",
+		Emoji:                 false,
+		IgnoreExifOrientation: false,
+		Rotate:                0,
+		RotateFillColor:       [4]int{0, 0, 0, 0},
+		Crop:                  nil,
+		CropPercent:           nil,
+		AutoTrim:              false,
+		PadAspect:             0,
+		Matte:                 nil,
+		AlphaCutoff:           0,
+		BackgroundKey:         nil,
+		FloodFillBackground:   false,
+		BackgroundTolerance:   0,
+		Brightness:            0,
+		Contrast:              0,
+		Gamma:                 0,
+		Saturation:            0,
+		HueShift:              0,
+		Sharpen:               0,
+		Blur:                  0,
+		Equalization:          "",
+		EqualizeAcrossFrames:  false,
+		CharAspect:            0,
+		AutoCharAspect:        false,
+		FlipX:                 false,
+		FlipY:                 false,
+		FitMode:               "",
+		MaxWidth:              0,
+		MaxHeight:             0,
+		NoUpscale:             false,
+		Full:                  false,
+		FontFilePath:          "",
+		FontColor:             [3]int{255, 255, 255},
+		SaveBackgroundColor:   [4]int{0, 0, 0, 100},
+		Braille:               false,
+		Threshold:             128,
+		ThresholdMode:         "",
+		Dither:                false,
+		DitherMatrix:          "",
+		ResampleFilter:        "",
+		LinearLight:           false,
+		Luminance:             "",
+		GifPalette:            "",
+		GlobalGifPalette:      false,
+		NoGifDither:           false,
+		GifFrames:             nil,
+		GifFrameStep:          0,
+		GifSpeed:              0,
+		GifLoopCount:          0,
+		GifReverse:            false,
+		GifPingPong:           false,
+		StreamGif:             false,
+		Interactive:           false,
+		FrameRate:             0,
+		FrameDelay:            0,
+		OnlySave:              false,
 	}
 }
 
@@ -79,32 +132,9 @@ the returned ascii art string.
 */
 func Convert(filePath string, flags Flags) (string, error) {
 
-	if flags.Dimensions == nil {
-		dimensions = nil
-	} else {
-		dimensions = flags.Dimensions
+	if err := applyFlags(flags); err != nil {
+		return "", err
 	}
-	width = flags.Width
-	height = flags.Height
-	complex = flags.Complex
-	saveTxtPath = flags.SaveTxtPath
-	saveImagePath = flags.SaveImagePath
-	saveGifPath = flags.SaveGifPath
-	negative = flags.Negative
-	colored = flags.Colored
-	colorBg = flags.CharBackgroundColor
-	grayscale = flags.Grayscale
-	customMap = flags.CustomMap
-	flipX = flags.FlipX
-	flipY = flags.FlipY
-	full = flags.Full
-	fontPath = flags.FontFilePath
-	fontColor = flags.FontColor
-	saveBgColor = flags.SaveBackgroundColor
-	braille = flags.Braille
-	threshold = flags.Threshold
-	dither = flags.Dither
-	onlySave = flags.OnlySave
 
 	inputIsGif = path.Ext(filePath) == ".gif"
 
@@ -184,24 +214,238 @@ func Convert(filePath string, flags Flags) (string, error) {
 		}
 	}
 
+	if err := loadFlagResources(flags); err != nil {
+		return "", err
+	}
+
+	if inputIsGif {
+		return "", pathIsGif(filePath, urlImgName, pathIsURl, urlImgBytes, pipedInputBytes, localFile)
+	}
+
+	// Animated PNG and WebP files are converted like gifs
+	anim, isAnimated, err := getAnimatedInput(filePath, pathIsURl, urlImgBytes, pipedInputBytes, localFile)
+	if err != nil {
+		return "", err
+	}
+	if isAnimated {
+		inputIsGif = true
+		return "", convertAnimation(anim, filePath, urlImgName)
+	}
+
+	return pathIsImage(filePath, urlImgName, pathIsURl, urlImgBytes, pipedInputBytes, localFile)
+}
+
+/*
+IsAnimated() reports whether the file at the passed path is a gif, or an animated PNG or WebP file. Only the start
+of the file is read. Urls and files that can't be read are reported as not animated
+*/
+func IsAnimated(filePath string) bool {
+
+	if path.Ext(filePath) == ".gif" {
+		return true
+	}
+	if filePath == "-" || isURL(filePath) {
+		return false
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	isAnimated, _ := isAnimatedImage(bufio.NewReader(file))
+	return isAnimated
+}
+
+/*
+Decodes the input as an animation if it's an animated PNG or WebP file, and returns false otherwise. Only the start
+of local files is read unless they're animated, and they're read from the start again afterwards, so that they
+can still be decoded as images
+*/
+func getAnimatedInput(filePath string, pathIsURl bool, urlImgBytes, pipedInputBytes []byte, localFile *os.File) (animation, bool, error) {
+
+	var (
+		inputBytes []byte
+		isAnimated bool
+		err        error
+	)
+
+	if filePath == "-" {
+		inputBytes = pipedInputBytes
+		isAnimated, err = isAnimatedImage(bytes.NewReader(inputBytes))
+	} else if pathIsURl {
+		inputBytes = urlImgBytes
+		isAnimated, err = isAnimatedImage(bytes.NewReader(inputBytes))
+	} else {
+		isAnimated, err = isAnimatedImage(bufio.NewReader(localFile))
+		if err == nil && isAnimated {
+			if _, err = localFile.Seek(0, io.SeekStart); err == nil {
+				inputBytes, err = ioutil.ReadAll(localFile)
+			}
+		}
+		if err == nil {
+			_, err = localFile.Seek(0, io.SeekStart)
+		}
+	}
+	if err != nil {
+		return animation{}, false, fmt.Errorf("unable to read file: %v", err)
+	}
+
+	if !isAnimated {
+		return animation{}, false, nil
+	}
+
+	anim, isAnimated, err := decodeAnimatedImage(inputBytes)
+	if err != nil {
+		if filePath == "-" {
+			return animation{}, false, fmt.Errorf("can't decode piped input: %v", err)
+		}
+		return animation{}, false, fmt.Errorf("can't decode %v: %v", filePath, err)
+	}
+
+	return anim, isAnimated, nil
+}
+
+// Copies the passed flags to the package's variables, which the rest of the conversion reads. Unknown names
+// of dither matrices, threshold modes, luminance models and gif palettes are rejected before anything is copied
+func applyFlags(flags Flags) error {
+
+	if flags.DitherMatrix != "" && !imgManip.IsDitherMatrix(flags.DitherMatrix) {
+		return fmt.Errorf("unknown dither matrix %v", flags.DitherMatrix)
+	}
+	if flags.ThresholdMode != "" && flags.ThresholdMode != "auto" && flags.ThresholdMode != "adaptive" {
+		return fmt.Errorf("unknown threshold mode %v", flags.ThresholdMode)
+	}
+	if flags.Luminance != "" && !imgManip.IsLuminanceModel(flags.Luminance) {
+		return fmt.Errorf("unknown luminance model %v", flags.Luminance)
+	}
+	if flags.GifPalette != "" && !imgManip.IsPaletteMethod(flags.GifPalette) {
+		return fmt.Errorf("unknown gif palette %v", flags.GifPalette)
+	}
+
+	if flags.Dimensions == nil {
+		dimensions = nil
+	} else {
+		dimensions = flags.Dimensions
+	}
+	width = flags.Width
+	height = flags.Height
+	complex = flags.Complex
+	saveTxtPath = flags.SaveTxtPath
+	saveImagePath = flags.SaveImagePath
+	saveGifPath = flags.SaveGifPath
+	negative = flags.Negative
+	colored = flags.Colored
+	colorBg = flags.CharBackgroundColor
+	grayscale = flags.Grayscale
+	customMap = flags.CustomMap
+	fillText = flags.FillText
+	emoji = flags.Emoji
+	ignoreExifOrientation = flags.IgnoreExifOrientation
+	rotate = flags.Rotate
+	rotateFillColor = flags.RotateFillColor
+	crop = flags.Crop
+	cropPercent = flags.CropPercent
+	autoTrim = flags.AutoTrim
+	padAspect = flags.PadAspect
+	matte = flags.Matte
+	alphaCutoff = flags.AlphaCutoff
+	backgroundKey = flags.BackgroundKey
+	floodFillBackground = flags.FloodFillBackground
+	backgroundTolerance = flags.BackgroundTolerance
+	adjustments = imgManip.Adjustments{
+		Brightness: flags.Brightness,
+		Contrast:   flags.Contrast,
+		Gamma:      flags.Gamma,
+		Saturation: flags.Saturation,
+		HueShift:   flags.HueShift,
+		Sharpen:    flags.Sharpen,
+		Blur:       flags.Blur,
+
+		Luminance:    flags.Luminance,
+		Equalization: flags.Equalization,
+	}
+	equalizeAcrossFrames = flags.EqualizeAcrossFrames
+	flipX = flags.FlipX
+	flipY = flags.FlipY
+	full = flags.Full
+	sizing = imgManip.Sizing{
+		FitMode:   flags.FitMode,
+		MaxWidth:  flags.MaxWidth,
+		MaxHeight: flags.MaxHeight,
+		NoUpscale: flags.NoUpscale,
+	}
+	fontPath = flags.FontFilePath
+	fontColor = flags.FontColor
+	saveBgColor = flags.SaveBackgroundColor
+	braille = flags.Braille
+	threshold = flags.Threshold
+	thresholdMode = flags.ThresholdMode
+	dither = flags.Dither
+	ditherMatrix = flags.DitherMatrix
+	resampleFilter = flags.ResampleFilter
+	linearLight = flags.LinearLight
+	gifPalette = flags.GifPalette
+	globalGifPalette = flags.GlobalGifPalette
+	noGifDither = flags.NoGifDither
+	gifFrameRange = flags.GifFrames
+	gifFrameStep = flags.GifFrameStep
+	gifSpeed = flags.GifSpeed
+	gifLoopCount = flags.GifLoopCount
+	gifReverse = flags.GifReverse
+	gifPingPong = flags.GifPingPong
+	streamGif = flags.StreamGif
+	interactive = flags.Interactive
+	frameRate = flags.FrameRate
+	frameDelay = flags.FrameDelay
+	onlySave = flags.OnlySave
+
+	return nil
+}
+
+// Loads the fill text and font files set by the passed flags, and resolves the character aspect ratio
+func loadFlagResources(flags Flags) error {
+
+	// If path to fill text file is provided, use its contents
+	if flags.FillTextFilePath != "" {
+		fillTextBytes, err := ioutil.ReadFile(flags.FillTextFilePath)
+		if err != nil {
+			return fmt.Errorf("unable to open fill text file: %v", err)
+		}
+
+		// A file of only whitespace would silently fall back to the character table
+		fillText = strings.TrimSpace(string(fillTextBytes))
+		if fillText == "" {
+			return fmt.Errorf("fill text file %v has no non-whitespace characters", flags.FillTextFilePath)
+		}
+	}
+
 	// If path to font file is provided, use it
 	if fontPath != "" {
 		fontFile, err := ioutil.ReadFile(fontPath)
 		if err != nil {
-			return "", fmt.Errorf("unable to open font file: %v", err)
+			return fmt.Errorf("unable to open font file: %v", err)
 		}
 
 		// tempFont is globally declared in aic_package/create_ascii_image.go
 		if tempFont, err = truetype.Parse(fontFile); err != nil {
-			return "", fmt.Errorf("unable to parse font file: %v", err)
+			return fmt.Errorf("unable to parse font file: %v", err)
 		}
 	} else if braille {
 		tempFont, _ = truetype.Parse(embeddedDejaVuObliqueFont)
+	} else if emoji && (saveImagePath != "" || saveGifPath != "") {
+		return fmt.Errorf("saving emojis as images requires a font with emoji glyphs")
 	}
 
-	if inputIsGif {
-		return "", pathIsGif(filePath, urlImgName, pathIsURl, urlImgBytes, pipedInputBytes, localFile)
-	} else {
-		return pathIsImage(filePath, urlImgName, pathIsURl, urlImgBytes, pipedInputBytes, localFile)
+	// Resolved after loading the font, since the automatic ratio may be derived from it
+	charAspect = flags.CharAspect
+	if flags.AutoCharAspect {
+		charAspect = getAutoCharAspect()
+	}
+	if charAspect <= 0 {
+		charAspect = 2
 	}
+
+	return nil
 }
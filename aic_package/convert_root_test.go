@@ -0,0 +1,59 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyFlagsUnknownNames(t *testing.T) {
+
+	defer applyFlags(DefaultFlags())
+
+	tests := []struct {
+		name  string
+		apply func(flags *Flags)
+	}{
+		{"dither matrix", func(flags *Flags) { flags.DitherMatrix = "bayer-3x3" }},
+		{"threshold mode", func(flags *Flags) { flags.ThresholdMode = "otsu" }},
+		{"luminance model", func(flags *Flags) { flags.Luminance = "rec2020" }},
+		{"gif palette", func(flags *Flags) { flags.GifPalette = "web-safe" }},
+	}
+
+	for _, test := range tests {
+		flags := DefaultFlags()
+		test.apply(&flags)
+
+		if err := applyFlags(flags); err == nil {
+			t.Errorf("applyFlags() with an unknown %v didn't fail", test.name)
+		}
+		// Flags are checked before the file is opened
+		if _, err := Convert("missing.png", flags); err == nil || !strings.HasPrefix(err.Error(), "unknown") {
+			t.Errorf("Convert() with an unknown %v = %v, want an error for it", test.name, err)
+		}
+	}
+
+	flags := DefaultFlags()
+	flags.DitherMatrix = "atkinson"
+	flags.ThresholdMode = "adaptive"
+	flags.Luminance = "lstar"
+	flags.GifPalette = "octree"
+	if err := applyFlags(flags); err != nil {
+		t.Errorf("applyFlags() with known names failed: %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
+)
+
+// Extensions of the files that are picked from directories passed to ConvertSequence()
+var sequenceImageExtensions = []string{
+	".jpg",
+	".jpeg",
+	".png",
+	".webp",
+	".bmp",
+	".tiff",
+	".tif",
+}
+
+/*
+ConvertSequence() takes paths of still images, directories of them or glob patterns matching them as its first
+argument, and converts the images as frames of one animation in the passed order. Images in a directory or
+matched by a pattern are sorted by name, with numbers compared by value so that frame_2 comes before frame_10.
+
+The animation is played and saved like a gif, showing frames for Flags.FrameDelay milliseconds or at
+Flags.FrameRate frames per second. All frames must have the same dimensions.
+*/
+func ConvertSequence(paths []string, flags Flags) error {
+
+	if err := applyFlags(flags); err != nil {
+		return err
+	}
+	inputIsGif = true
+
+	framePaths, err := getSequenceFramePaths(paths)
+	if err != nil {
+		return err
+	}
+
+	if err := loadFlagResources(flags); err != nil {
+		return err
+	}
+
+	anim, err := newImageSequence(framePaths)
+	if err != nil {
+		return err
+	}
+
+	// Saved files are named after the directory if one was passed, and after the first frame otherwise
+	inputPath := framePaths[0]
+	if fileInfo, err := os.Stat(paths[0]); err == nil && fileInfo.IsDir() {
+		inputPath = filepath.Clean(paths[0])
+	}
+
+	return convertAnimation(anim, inputPath, "")
+}
+
+// Expands the directories and glob patterns among the passed paths into the image files they hold
+func getSequenceFramePaths(paths []string) ([]string, error) {
+
+	var framePaths []string
+
+	for _, inputPath := range paths {
+
+		if inputPath == "-" || isURL(inputPath) {
+			return nil, fmt.Errorf("image sequences can only be made from local files")
+		}
+
+		fileInfo, err := os.Stat(inputPath)
+
+		if err == nil && fileInfo.IsDir() {
+			entries, err := ioutil.ReadDir(inputPath)
+			if err != nil {
+				return nil, fmt.Errorf("unable to open directory: %v", err)
+			}
+
+			var names []string
+			for _, entry := range entries {
+				if !entry.IsDir() && isSequenceImage(entry.Name()) {
+					names = append(names, entry.Name())
+				}
+			}
+			if len(names) == 0 {
+				return nil, fmt.Errorf("%v has no images", inputPath)
+			}
+
+			sortNaturally(names)
+			for _, name := range names {
+				framePaths = append(framePaths, filepath.Join(inputPath, name))
+			}
+
+		} else if err != nil && strings.ContainsAny(inputPath, "*?[") {
+			// Patterns are expanded here, since shells don't expand quoted ones and some don't expand any
+			matches, err := filepath.Glob(inputPath)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %v: %v", inputPath, err)
+			}
+
+			var files []string
+			for _, match := range matches {
+				if matchInfo, err := os.Stat(match); err == nil && !matchInfo.IsDir() {
+					files = append(files, match)
+				}
+			}
+			if len(files) == 0 {
+				return nil, fmt.Errorf("no files match %v", inputPath)
+			}
+
+			sortNaturally(files)
+			framePaths = append(framePaths, files...)
+
+		} else {
+			framePaths = append(framePaths, inputPath)
+		}
+	}
+
+	return framePaths, nil
+}
+
+// Returns true if the passed file name has one of sequenceImageExtensions
+func isSequenceImage(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+
+	for _, imageExtension := range sequenceImageExtensions {
+		if extension == imageExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// Sorts the passed names alphabetically, except that runs of digits are compared by their value
+func sortNaturally(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return naturalLess(names[i], names[j])
+	})
+}
+
+// Reports whether a sorts before b, comparing runs of digits by their value
+func naturalLess(a, b string) bool {
+
+	for len(a) > 0 && len(b) > 0 {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+
+		if aDigits > 0 && bDigits > 0 {
+			aNumber := strings.TrimLeft(a[:aDigits], "0")
+			bNumber := strings.TrimLeft(b[:bDigits], "0")
+
+			// Without leading zeros, a longer number is a larger one
+			if len(aNumber) != len(bNumber) {
+				return len(aNumber) < len(bNumber)
+			}
+			if aNumber != bNumber {
+				return aNumber < bNumber
+			}
+
+			a, b = a[aDigits:], b[bDigits:]
+			continue
+		}
+
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) < len(b)
+}
+
+// Returns the number of digits at the start of the passed string
+func leadingDigits(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+/*
+Builds an animation out of the image files at the passed paths. Only the dimensions of each image are read here,
+while the images themselves are decoded as they're drawn, so that long sequences don't have to fit in memory
+*/
+func newImageSequence(framePaths []string) (animation, error) {
+
+	var (
+		frames = make([]encodedFrame, len(framePaths))
+		delays = make([]int, len(framePaths))
+		delay  = getSequenceDelay()
+		canvas image.Rectangle
+	)
+
+	for i, framePath := range framePaths {
+
+		frameWidth, frameHeight, err := getSequenceFrameSize(framePath)
+		if err != nil {
+			return animation{}, err
+		}
+
+		if i == 0 {
+			canvas = image.Rect(0, 0, frameWidth, frameHeight)
+		} else if frameWidth != canvas.Dx() || frameHeight != canvas.Dy() {
+			return animation{}, fmt.Errorf("%v is %dx%d, but the first frame is %dx%d", framePath, frameWidth, frameHeight, canvas.Dx(), canvas.Dy())
+		}
+
+		framePath := framePath
+		frames[i] = encodedFrame{
+			rect:     canvas,
+			blend:    false,
+			disposal: gif.DisposalNone,
+			decode: func() (image.Image, error) {
+				return decodeSequenceFrame(framePath)
+			},
+		}
+		delays[i] = delay
+	}
+
+	anim := animation{
+		frameCount: len(frames),
+		delays:     delays,
+		loopCount:  0,
+
+		newCompositor: func() func(keep bool) (image.Image, error) {
+			return newEncodedFrameCompositor(canvas, frames)
+		},
+	}
+
+	return anim, nil
+}
+
+/*
+Returns the dimensions of an image of a sequence once it's turned upright according to its EXIF orientation.
+Only the start of the file is read, which holds the header and the EXIF data of JPEG files, except for TIFF files
+that may keep their tags anywhere
+*/
+func getSequenceFrameSize(framePath string) (int, int, error) {
+
+	file, err := os.Open(framePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to open file: %v", err)
+	}
+	defer file.Close()
+
+	// JPEG metadata segments are at most 64 KiB each, and only a few come before the image data
+	head, err := ioutil.ReadAll(io.LimitReader(file, 256*1024))
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to read file: %v", err)
+	}
+
+	config, format, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		return 0, 0, fmt.Errorf("can't decode %v: %v", framePath, err)
+	}
+
+	if ignoreExifOrientation {
+		return config.Width, config.Height, nil
+	}
+
+	if format == "tiff" {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return 0, 0, fmt.Errorf("unable to read file: %v", err)
+		}
+		if head, err = ioutil.ReadAll(file); err != nil {
+			return 0, 0, fmt.Errorf("unable to read file: %v", err)
+		}
+	}
+
+	// Orientations from 5 to 8 turn images sideways
+	if imgManip.GetExifOrientation(head) >= 5 {
+		return config.Height, config.Width, nil
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// Decodes an image of a sequence and turns it upright according to its EXIF orientation
+func decodeSequenceFrame(framePath string) (image.Image, error) {
+
+	imBytes, err := ioutil.ReadFile(framePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %v", err)
+	}
+
+	imData, _, err := image.Decode(bytes.NewReader(imBytes))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode %v: %v", framePath, err)
+	}
+
+	if !ignoreExifOrientation {
+		imData = imgManip.ApplyExifOrientation(imData, imgManip.GetExifOrientation(imBytes))
+	}
+
+	return imData, nil
+}
+
+// Returns the delay between frames of an image sequence in hundredths of a second, which gifs are limited to
+func getSequenceDelay() int {
+
+	delay := 10.0
+	if frameDelay > 0 {
+		delay = float64(frameDelay) / 10
+	} else if frameRate > 0 {
+		delay = 100 / frameRate
+	}
+
+	return int(math.Max(1, math.Round(delay)))
+}
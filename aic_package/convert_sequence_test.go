@@ -0,0 +1,165 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Writes a PNG file filled with the passed color into dir and returns its path
+func writeSequenceFrame(t *testing.T, dir, name string, width, height int, fill color.NRGBA) string {
+	t.Helper()
+
+	framePath := filepath.Join(dir, name)
+	file, err := os.Create(framePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, solidImage(width, height, fill)); err != nil {
+		t.Fatal(err)
+	}
+	return framePath
+}
+
+func TestNaturalLess(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"frame_2.png", "frame_10.png", true},
+		{"frame_10.png", "frame_2.png", false},
+		{"frame_02.png", "frame_10.png", true},
+		// Equal numbers fall back to the rest of the name
+		{"frame_1a.png", "frame_1b.png", true},
+		{"frame_007.png", "frame_7.png", false},
+		{"a.png", "b.png", true},
+		{"frame.png", "frame_1.png", true},
+		{"same.png", "same.png", false},
+		// Numbers larger than any integer type are still compared by value
+		{"99999999999999999999.png", "100000000000000000000.png", true},
+	}
+
+	for _, test := range tests {
+		if got := naturalLess(test.a, test.b); got != test.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSortNaturally(t *testing.T) {
+
+	names := []string{"frame_10.png", "frame_1.png", "frame_9.png", "frame_2.png", "frame_100.png"}
+	sortNaturally(names)
+
+	want := []string{"frame_1.png", "frame_2.png", "frame_9.png", "frame_10.png", "frame_100.png"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sorted names = %v, want %v", names, want)
+	}
+}
+
+func TestGetSequenceDelay(t *testing.T) {
+
+	defer func() { frameRate, frameDelay = 0, 0 }()
+
+	tests := []struct {
+		rate  float64
+		delay int
+		want  int
+	}{
+		{0, 0, 10},
+		{24, 0, 4},
+		{30, 0, 3},
+		{0, 50, 5},
+		{0, 45, 5},
+		{0, 1, 1},
+		{1000, 0, 1},
+		// The delay overrides the frame rate
+		{24, 200, 20},
+	}
+
+	for _, test := range tests {
+		frameRate, frameDelay = test.rate, test.delay
+		if got := getSequenceDelay(); got != test.want {
+			t.Errorf("getSequenceDelay() with fps %v and delay %v = %v, want %v", test.rate, test.delay, got, test.want)
+		}
+	}
+}
+
+func TestNewImageSequence(t *testing.T) {
+
+	dir := t.TempDir()
+	framePaths := []string{
+		writeSequenceFrame(t, dir, "frame_1.png", 6, 4, testRed),
+		writeSequenceFrame(t, dir, "frame_2.png", 6, 4, testGreen),
+	}
+
+	anim, err := newImageSequence(framePaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim.frameCount != 2 {
+		t.Fatalf("frameCount = %d, want 2", anim.frameCount)
+	}
+	checkColors(t, "frames", colorsAt(t, anim, image.Pt(5, 3)), []color.NRGBA{testRed, testGreen})
+
+	// Frames are only decoded while they're drawn, so a file that breaks afterwards fails the frame
+	// instead of repeating the one before it
+	if err := ioutil.WriteFile(framePaths[1], []byte("not an image"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	nextFrame := anim.newCompositor()
+	if _, err := nextFrame(true); err != nil {
+		t.Fatalf("first frame: %v", err)
+	}
+	if _, err := nextFrame(true); err == nil {
+		t.Error("drawing an undecodable frame didn't fail")
+	}
+
+	if err := os.Remove(framePaths[1]); err != nil {
+		t.Fatal(err)
+	}
+	nextFrame = anim.newCompositor()
+	nextFrame(true)
+	if _, err := nextFrame(true); err == nil {
+		t.Error("drawing a missing frame didn't fail")
+	}
+}
+
+func TestNewImageSequenceErrors(t *testing.T) {
+
+	dir := t.TempDir()
+	first := writeSequenceFrame(t, dir, "frame_1.png", 6, 4, testRed)
+
+	if _, err := newImageSequence([]string{first, writeSequenceFrame(t, dir, "frame_2.png", 4, 6, testRed)}); err == nil {
+		t.Error("newImageSequence() of frames with different dimensions didn't fail")
+	}
+
+	if _, err := newImageSequence([]string{first, filepath.Join(dir, "missing.png")}); err == nil {
+		t.Error("newImageSequence() of a missing file didn't fail")
+	}
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package aic_package
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"image/gif"
+	"io"
 
 	_ "embed"
 
@@ -43,7 +46,7 @@ func createGifFrameToSave(asciiArt [][]imgManip.AsciiChar, img image.Image, colo
 	y := img.Bounds().Dy()
 
 	// Ascii art dimensions
-	asciiWidth := len(asciiArt[0])
+	asciiWidth := getAsciiArtColumns(asciiArt)
 	asciiHeight := len(asciiArt)
 
 	// Iterators to move pointer on the image to be made
@@ -53,10 +56,10 @@ func createGifFrameToSave(asciiArt [][]imgManip.AsciiChar, img image.Image, colo
 	var fontSize float64
 
 	// Conditions to alter resulting ascii gif dimensions according to ascii art dimensions
-	if asciiWidth > asciiHeight*2 {
+	if float64(asciiWidth) > float64(asciiHeight)*charAspect {
 		yIter = float64(y) / float64(asciiHeight)
 
-		xIter = yIter / 2
+		xIter = yIter / charAspect
 		x = int(xIter * float64(asciiWidth))
 
 		fontSize = xIter
@@ -64,7 +67,7 @@ func createGifFrameToSave(asciiArt [][]imgManip.AsciiChar, img image.Image, colo
 	} else {
 		xIter = float64(x) / float64(asciiWidth)
 
-		yIter = xIter * 2
+		yIter = xIter * charAspect
 		y = int(yIter * float64(asciiHeight))
 
 		fontSize = xIter
@@ -108,6 +111,16 @@ func createGifFrameToSave(asciiArt [][]imgManip.AsciiChar, img image.Image, colo
 
 		for _, char := range line {
 
+			// Fill the character's cell first if it carries its own background color
+			if colored && char.HasBackground {
+				r := uint8(char.BgRgbValue[0])
+				g := uint8(char.BgRgbValue[1])
+				b := uint8(char.BgRgbValue[2])
+				dc.SetColor(color.RGBA{r, g, b, 255})
+				dc.DrawRectangle(xImgPointer, yImgPointer, xIter*float64(imgManip.DisplayWidth(char.Simple)), yIter)
+				dc.Fill()
+			}
+
 			if colored {
 				// dc.SetColor() sets color for EACH character before printing it
 				r := uint8(char.RgbValue[0])
@@ -125,8 +138,8 @@ func createGifFrameToSave(asciiArt [][]imgManip.AsciiChar, img image.Image, colo
 			dc.DrawStringWrapped(char.Simple, xImgPointer, yImgPointer, 0, 0, float64(x), 1.8, gg.AlignLeft)
 
 			// Incremet x-axis pointer character so new one can be printed after it
-			// Set to the same constant as in line
-			xImgPointer += xIter
+			// Set to the same constant as in line, once for each column the character occupies
+			xImgPointer += xIter * float64(imgManip.DisplayWidth(char.Simple))
 		}
 
 		dc.DrawStringWrapped("\n", xImgPointer, yImgPointer, 0, 0, float64(x), 1.8, gg.AlignLeft)
@@ -138,3 +151,258 @@ func createGifFrameToSave(asciiArt [][]imgManip.AsciiChar, img image.Image, colo
 
 	return dc.Image(), nil
 }
+
+/*
+Shrinks gif frames passed to add() before passing them on to emit. Consecutive frames that look identical are
+merged into one, summing their delays. Every other frame after the first is cropped to the rectangle that changed
+since the previous frame, and pixels inside it that didn't change are made transparent if the frame's palette has
+room for a transparent color, or an index that no changed pixel uses, which compresses better. Each frame is drawn
+over the previous ones, so no frame is disposed.
+
+Frames are passed on one frame late, since the next frame decides whether it's merged into them. flush() passes
+on the last one
+*/
+type gifFrameOptimizer struct {
+	emit func(frame *image.Paletted, delay int) error
+
+	// Colors currently shown on the canvas as RGBA bytes, 4 per pixel, which each frame is compared against
+	bounds image.Rectangle
+	canvas []uint8
+
+	held      *image.Paletted
+	heldDelay int
+}
+
+func newGifFrameOptimizer(emit func(frame *image.Paletted, delay int) error) *gifFrameOptimizer {
+	return &gifFrameOptimizer{emit: emit}
+}
+
+// Returns the colors of the passed palette as RGBA bytes, padded with transparency up to 256 entries so that
+// any color index can be looked up
+func getPaletteBytes(p color.Palette) [256][4]uint8 {
+	var colors [256][4]uint8
+
+	for i, c := range p {
+		if i == len(colors) {
+			break
+		}
+		rgba := color.RGBAModel.Convert(c).(color.RGBA)
+		colors[i] = [4]uint8{rgba.R, rgba.G, rgba.B, rgba.A}
+	}
+
+	return colors
+}
+
+// Returns the offset of the pixel at (x, y) in the canvas
+func (o *gifFrameOptimizer) canvasOffset(x, y int) int {
+	return ((y-o.bounds.Min.Y)*o.bounds.Dx() + (x - o.bounds.Min.X)) * 4
+}
+
+func (o *gifFrameOptimizer) drawOnCanvas(frame *image.Paletted, colors *[256][4]uint8) {
+	for y := o.bounds.Min.Y; y < o.bounds.Max.Y; y++ {
+		for x := o.bounds.Min.X; x < o.bounds.Max.X; x++ {
+			copy(o.canvas[o.canvasOffset(x, y):], colors[frame.ColorIndexAt(x, y)][:])
+		}
+	}
+}
+
+func (o *gifFrameOptimizer) add(frame *image.Paletted, delay int) error {
+
+	colors := getPaletteBytes(frame.Palette)
+
+	if o.canvas == nil {
+		o.bounds = frame.Bounds()
+		o.canvas = make([]uint8, o.bounds.Dx()*o.bounds.Dy()*4)
+		o.drawOnCanvas(frame, &colors)
+
+		o.held, o.heldDelay = frame, delay
+		return nil
+	}
+
+	bounds := o.bounds
+
+	isChanged := func(x, y int) bool {
+		i := o.canvasOffset(x, y)
+		return colors[frame.ColorIndexAt(x, y)] != [4]uint8{o.canvas[i], o.canvas[i+1], o.canvas[i+2], o.canvas[i+3]}
+	}
+
+	// Bounding rectangle of the changed pixels, and the color indices they use
+	var (
+		changed     image.Rectangle
+		usedIndices [256]bool
+	)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if isChanged(x, y) {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+				usedIndices[frame.ColorIndexAt(x, y)] = true
+			}
+		}
+	}
+
+	if changed.Empty() {
+		o.heldDelay += delay
+		return nil
+	}
+
+	framePalette := frame.Palette
+	transparentIndex := -1
+
+	if len(framePalette) < 256 {
+		transparentIndex = len(framePalette)
+		framePalette = append(append(color.Palette{}, framePalette...), color.Transparent)
+	} else {
+		// Full palettes, such as plan9, lend an index that no changed pixel uses to transparency
+		for i := range framePalette {
+			if !usedIndices[i] {
+				transparentIndex = i
+				framePalette = append(color.Palette{}, framePalette...)
+				framePalette[i] = color.Transparent
+				break
+			}
+		}
+	}
+
+	delta := image.NewPaletted(changed, framePalette)
+
+	// Unchanged pixels keep their color if it continues the run of the pixel before them, since breaking runs
+	// compresses worse than the transparent color saves
+	previous := -1
+
+	for y := changed.Min.Y; y < changed.Max.Y; y++ {
+		for x := changed.Min.X; x < changed.Max.X; x++ {
+			index := int(frame.ColorIndexAt(x, y))
+
+			if transparentIndex != -1 && index != previous && !isChanged(x, y) {
+				index = transparentIndex
+			}
+
+			delta.SetColorIndex(x, y, uint8(index))
+			previous = index
+		}
+	}
+
+	o.drawOnCanvas(frame, &colors)
+
+	if err := o.emit(o.held, o.heldDelay); err != nil {
+		return err
+	}
+	o.held, o.heldDelay = delta, delay
+
+	return nil
+}
+
+func (o *gifFrameOptimizer) flush() error {
+	if o.held == nil {
+		return nil
+	}
+
+	held := o.held
+	o.held = nil
+
+	return o.emit(held, o.heldDelay)
+}
+
+// Shrinks all of the passed gif frames with gifFrameOptimizer, returning the new frames, their delays and their
+// disposal methods
+func optimizeGifFrames(frames []*image.Paletted, delays []int) ([]*image.Paletted, []int, []byte) {
+
+	var (
+		optimizedFrames []*image.Paletted
+		optimizedDelays []int
+		disposals       []byte
+	)
+
+	optimizer := newGifFrameOptimizer(func(frame *image.Paletted, delay int) error {
+		optimizedFrames = append(optimizedFrames, frame)
+		optimizedDelays = append(optimizedDelays, delay)
+		disposals = append(disposals, gif.DisposalNone)
+		return nil
+	})
+
+	for i, frame := range frames {
+		optimizer.add(frame, delays[i])
+	}
+	optimizer.flush()
+
+	return optimizedFrames, optimizedDelays, disposals
+}
+
+/*
+Writes a gif one frame at a time, so that its frames don't have to be kept in memory. Each frame is encoded on its
+own by image/gif, and copied without the header and trailer of the encoded gif. The gif takes the size of its first
+frame, and no frame is disposed
+*/
+type gifStreamWriter struct {
+	w         io.Writer
+	loopCount int
+
+	width   int
+	height  int
+	started bool
+}
+
+func newGifStreamWriter(w io.Writer, loopCount int) *gifStreamWriter {
+	return &gifStreamWriter{w: w, loopCount: loopCount}
+}
+
+// Writes the gif's header, logical screen descriptor without a global color table, and looping extension
+func (s *gifStreamWriter) writeHeader() error {
+
+	header := []byte("GIF89a")
+	header = append(header, byte(s.width), byte(s.width>>8), byte(s.height), byte(s.height>>8), 0, 0, 0)
+
+	if s.loopCount >= 0 {
+		header = append(header, 0x21, 0xff, 0x0b)
+		header = append(header, "NETSCAPE2.0"...)
+		header = append(header, 0x03, 0x01, byte(s.loopCount), byte(s.loopCount>>8), 0x00)
+	}
+
+	s.started = true
+
+	_, err := s.w.Write(header)
+	return err
+}
+
+func (s *gifStreamWriter) writeFrame(frame *image.Paletted, delay int) error {
+
+	if !s.started {
+		s.width = frame.Bounds().Max.X
+		s.height = frame.Bounds().Max.Y
+
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	var buffer bytes.Buffer
+	err := gif.EncodeAll(&buffer, &gif.GIF{
+		Image:    []*image.Paletted{frame},
+		Delay:    []int{delay},
+		Disposal: []byte{gif.DisposalNone},
+		Config:   image.Config{Width: s.width, Height: s.height},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Without a global color table, the header and logical screen descriptor take 13 bytes, and the trailer 1
+	encoded := buffer.Bytes()
+	_, err = s.w.Write(encoded[13 : len(encoded)-1])
+
+	return err
+}
+
+// Writes the gif's trailer, after which no frames can be written
+func (s *gifStreamWriter) close() error {
+
+	if !s.started {
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.w.Write([]byte{0x3b})
+	return err
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"testing"
+)
+
+// Returns a paletted frame of the passed size filled with the first palette color, and with the passed
+// rectangle filled with the palette color at fillIndex
+func palettedFrame(width, height int, p color.Palette, rect image.Rectangle, fillIndex uint8) *image.Paletted {
+	frame := image.NewPaletted(image.Rect(0, 0, width, height), p)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			frame.SetColorIndex(x, y, fillIndex)
+		}
+	}
+	return frame
+}
+
+// Draws the passed optimized frames over each other through image/gif and checks that each matches the original
+func checkOptimizedFrames(t *testing.T, original, optimized []*image.Paletted, delays []int) {
+	t.Helper()
+
+	var file bytes.Buffer
+	err := gif.EncodeAll(&file, &gif.GIF{
+		Image:    optimized,
+		Delay:    delays,
+		Disposal: make([]byte, len(optimized)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := splitGifFrames(file.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nextFrame := anim.newCompositor()
+	for i := range original {
+		got, err := nextFrame(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := original[i].Bounds()
+
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				want := color.NRGBAModel.Convert(original[i].At(x, y))
+				if pixel := color.NRGBAModel.Convert(got.At(x, y)); pixel != want {
+					t.Fatalf("frame %d at (%d, %d) = %v, want %v", i, x, y, pixel, want)
+				}
+			}
+		}
+	}
+}
+
+func TestOptimizeGifFrames(t *testing.T) {
+
+	p := color.Palette{color.Black, color.White, testRed}
+
+	frames := []*image.Paletted{
+		palettedFrame(10, 10, p, image.Rect(0, 0, 10, 1), 1),
+		palettedFrame(10, 10, p, image.Rect(0, 0, 10, 1), 1),
+		palettedFrame(10, 10, p, image.Rect(0, 0, 10, 1), 1),
+	}
+
+	// The last frame changes a square and the end of the first row, so only the rectangle spanning both is kept
+	for y := 3; y < 6; y++ {
+		for x := 2; x < 5; x++ {
+			frames[2].SetColorIndex(x, y, 2)
+		}
+	}
+	frames[2].SetColorIndex(9, 0, 2)
+
+	optimized, delays, disposals := optimizeGifFrames(frames, []int{10, 20, 5})
+
+	if len(optimized) != 2 {
+		t.Fatalf("got %d frames, want 2", len(optimized))
+	}
+	// Identical frames are merged, summing their delays
+	if want := []int{30, 5}; !equalInts(delays, want) {
+		t.Errorf("delays = %v, want %v", delays, want)
+	}
+	if disposals[0] != gif.DisposalNone || disposals[1] != gif.DisposalNone {
+		t.Errorf("disposals = %v, want none", disposals)
+	}
+
+	if rect := optimized[1].Bounds(); rect != image.Rect(2, 0, 10, 6) {
+		t.Errorf("changed rectangle = %v, want %v", rect, image.Rect(2, 0, 10, 6))
+	}
+	// Unchanged pixels inside the rectangle are transparent
+	if _, _, _, a := optimized[1].At(6, 3).RGBA(); a != 0 {
+		t.Errorf("unchanged pixel isn't transparent")
+	}
+
+	checkOptimizedFrames(t, []*image.Paletted{frames[0], frames[2]}, optimized, delays)
+}
+
+func TestOptimizeGifFramesFullPalette(t *testing.T) {
+
+	// Plan9 has no room for a transparent color, so one that no changed pixel uses is lent to it
+	frames := []*image.Paletted{
+		palettedFrame(8, 8, palette.Plan9, image.Rect(0, 0, 8, 8), 255),
+		palettedFrame(8, 8, palette.Plan9, image.Rect(0, 0, 8, 8), 255),
+	}
+	frames[1].SetColorIndex(1, 1, 0)
+	frames[1].SetColorIndex(6, 6, 0)
+
+	optimized, delays, _ := optimizeGifFrames(frames, []int{1, 1})
+
+	if len(optimized) != 2 {
+		t.Fatalf("got %d frames, want 2", len(optimized))
+	}
+	if len(optimized[1].Palette) != 256 {
+		t.Errorf("palette has %d colors, want 256", len(optimized[1].Palette))
+	}
+	if _, _, _, a := optimized[1].At(3, 3).RGBA(); a != 0 {
+		t.Errorf("unchanged pixel isn't transparent")
+	}
+
+	checkOptimizedFrames(t, frames, optimized, delays)
+}
@@ -41,6 +41,32 @@ func init() {
 	tempFont, _ = truetype.Parse(embeddedHackRegularFont)
 }
 
+// Returns the line height of tempFont divided by the advance of its characters, which is the aspect ratio
+// of a character cell when text is set in it. Falls back to 2 if the font doesn't have the sampled glyphs
+func getFontCharAspect() float64 {
+
+	fontFace := truetype.NewFace(tempFont, &truetype.Options{Size: 64})
+
+	sample := 'M'
+	if braille {
+		sample = '\u28ff'
+	}
+
+	advance, ok := fontFace.GlyphAdvance(sample)
+	if !ok || advance <= 0 {
+		advance, ok = fontFace.GlyphAdvance('M')
+	}
+
+	metrics := fontFace.Metrics()
+	lineHeight := metrics.Ascent + metrics.Descent
+
+	if !ok || advance <= 0 || lineHeight <= 0 {
+		return 2
+	}
+
+	return float64(lineHeight) / float64(advance)
+}
+
 /*
 Unlike createGifFrameToSave(), this function is altered to ignore execution time and has a fixed font size.
 This creates maximum quality ascii art, although the resulting image will not have the same dimensions
@@ -49,18 +75,18 @@ images will considerably decrease ascii art quality because of smaller font size
 
 Size of resulting image may also be considerably larger than original image.
 */
-func createImageToSave(asciiArt [][]imgManip.AsciiChar, colored bool, saveImagePath, imagePath, urlImgName string, onlySave bool) error {
+func createImageToSave(asciiArt [][]imgManip.AsciiChar, colored bool, saveImagePath, imagePath, urlImgName, label string, onlySave bool) error {
 
 	constant := 14.0
 
-	x := len(asciiArt[0])
+	// Width is counted in columns since double-width characters take up two
+	x := getAsciiArtColumns(asciiArt)
 	y := len(asciiArt)
 
 	// Multipying resulting image dimensions with respect to constant
 	x = int(constant * float64(x))
 
-	y = int(constant * float64(y))
-	y = y * 2
+	y = int(constant * float64(y) * charAspect)
 
 	// 10 extra pixels on both x and y-axis to have 5 pixels of padding on each side
 	y += 10
@@ -102,6 +128,16 @@ func createImageToSave(asciiArt [][]imgManip.AsciiChar, colored bool, saveImageP
 
 		for _, char := range line {
 
+			// Fill the character's cell first if it carries its own background color
+			if colored && char.HasBackground {
+				r := uint8(char.BgRgbValue[0])
+				g := uint8(char.BgRgbValue[1])
+				b := uint8(char.BgRgbValue[2])
+				dc.SetColor(color.RGBA{r, g, b, 255})
+				dc.DrawRectangle(xImgPointer, yImgPointer, constant*float64(imgManip.DisplayWidth(char.Simple)), constant*charAspect)
+				dc.Fill()
+			}
+
 			if colored {
 				// dc.SetColor() sets color for EACH character before printing it
 				r := uint8(char.RgbValue[0])
@@ -119,18 +155,18 @@ func createImageToSave(asciiArt [][]imgManip.AsciiChar, colored bool, saveImageP
 			dc.DrawStringWrapped(char.Simple, xImgPointer, yImgPointer, 0, 0, float64(x), 1.8, gg.AlignLeft)
 
 			// Incremet x-axis pointer character so new one can be printed after it
-			// Set to the same constant as in line
-			xImgPointer += float64(constant)
+			// Set to the same constant as in line, once for each column the character occupies
+			xImgPointer += float64(constant) * float64(imgManip.DisplayWidth(char.Simple))
 		}
 
 		dc.DrawStringWrapped("\n", xImgPointer, yImgPointer, 0, 0, float64(x), 1.8, gg.AlignLeft)
 
 		// Incremet pointer for y axis after every line printed, so
 		// new line can start at below the previous one on next iteration
-		yImgPointer += float64(constant * 2)
+		yImgPointer += constant * charAspect
 	}
 
-	imageName, err := createSaveFileName(imagePath, urlImgName, "-ascii-art.png")
+	imageName, err := createSaveFileName(imagePath, urlImgName, label)
 	if err != nil {
 		return err
 	}
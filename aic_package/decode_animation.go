@@ -0,0 +1,571 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/webp"
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	webpHeader   = []byte("WEBP")
+)
+
+/*
+Frame of an animated PNG or WebP file, or of an image sequence. Frames are stored encoded and only decoded while
+they're drawn, so that whole animations don't have to be kept in memory
+*/
+type encodedFrame struct {
+	rect image.Rectangle
+
+	// Whether the frame is drawn over the canvas, or replaces the area under it
+	blend bool
+
+	// One of the disposal methods of gif frames
+	disposal byte
+
+	decode func() (image.Image, error)
+}
+
+/*
+Decodes the passed data as an animation if it's an animated PNG or WebP file. The returned bool is false for
+any other data, including PNG and WebP files with a single image, which should be converted like other images
+*/
+func decodeAnimatedImage(data []byte) (animation, bool, error) {
+
+	var (
+		canvas    image.Rectangle
+		frames    []encodedFrame
+		delays    []int
+		loopCount int
+		animated  bool
+		err       error
+	)
+
+	if bytes.HasPrefix(data, pngSignature) {
+		canvas, frames, delays, loopCount, animated, err = decodeAPNG(data)
+	} else if len(data) >= 12 && string(data[:4]) == "RIFF" && bytes.Equal(data[8:12], webpHeader) {
+		canvas, frames, delays, loopCount, animated, err = decodeAnimatedWebP(data)
+	}
+
+	if err != nil || !animated {
+		return animation{}, false, err
+	}
+
+	if len(frames) == 0 {
+		return animation{}, false, fmt.Errorf("animation has no frames")
+	}
+
+	anim := animation{
+		frameCount: len(frames),
+		delays:     delays,
+		loopCount:  loopCount,
+
+		newCompositor: func() func(keep bool) (image.Image, error) {
+			return newEncodedFrameCompositor(canvas, frames)
+		},
+	}
+
+	return anim, true, nil
+}
+
+/*
+Reports whether r holds an animated PNG or WebP file, reading only as far as needed to tell. For PNG files that's
+up to their image data, since the animation control chunk has to come before it
+*/
+func isAnimatedImage(r io.Reader) (bool, error) {
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	chunkHeader := make([]byte, 8)
+
+	if bytes.HasPrefix(header, pngSignature) {
+		// The first 4 bytes of the first chunk's header were read along with the signature
+		copy(chunkHeader, header[len(pngSignature):])
+		if _, err := io.ReadFull(r, chunkHeader[4:]); err != nil {
+			return false, nil
+		}
+
+		for {
+			switch string(chunkHeader[4:]) {
+			case "acTL":
+				return true, nil
+			case "IDAT", "IEND":
+				return false, nil
+			}
+
+			// Chunk contents and checksum are skipped
+			length := int64(binary.BigEndian.Uint32(chunkHeader)) + 4
+			if _, err := io.CopyN(io.Discard, r, length); err != nil {
+				return false, nil
+			}
+			if _, err := io.ReadFull(r, chunkHeader); err != nil {
+				return false, nil
+			}
+		}
+	}
+
+	if string(header[:4]) == "RIFF" && bytes.Equal(header[8:12], webpHeader) {
+		// Animated files start with an extended header, whose first byte holds the animation flag
+		extendedHeader := make([]byte, 9)
+		if _, err := io.ReadFull(r, extendedHeader); err != nil {
+			return false, nil
+		}
+		return string(extendedHeader[:4]) == "VP8X" && extendedHeader[8]&0x02 != 0, nil
+	}
+
+	return false, nil
+}
+
+/*
+Returns a function that draws the next frame of the passed ones on a canvas of the passed size each time it's
+called. Frames are placed at their offsets, and after each frame its area is cleared or restored according to its
+disposal method. Frames whose image data turns out to be corrupt return an error once they're drawn
+*/
+func newEncodedFrameCompositor(bounds image.Rectangle, frames []encodedFrame) func(keep bool) (image.Image, error) {
+
+	canvas := image.NewNRGBA(bounds)
+	index := 0
+
+	return func(keep bool) (image.Image, error) {
+
+		frame := frames[index]
+		index++
+
+		// A frame that the next one replaces entirely doesn't need to be drawn unless it's kept. This doesn't hold
+		// if the next frame is disposed to the previous canvas, which would then lack this frame
+		if !keep && index < len(frames) && !frames[index].blend && frames[index].rect == bounds && frames[index].disposal != gif.DisposalPrevious {
+			return nil, nil
+		}
+
+		var previous *image.NRGBA
+		if frame.disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(frame.rect)
+			draw.Draw(previous, frame.rect, canvas, frame.rect.Min, draw.Src)
+		}
+
+		frameImage, err := frame.decode()
+		if err != nil {
+			return nil, fmt.Errorf("can't decode frame %d: %v", index, err)
+		}
+
+		op := draw.Src
+		if frame.blend {
+			op = draw.Over
+		}
+		draw.Draw(canvas, frame.rect, frameImage, frameImage.Bounds().Min, op)
+
+		var composited image.Image
+		if keep {
+			copied := image.NewNRGBA(bounds)
+			copy(copied.Pix, canvas.Pix)
+			composited = copied
+		}
+
+		switch frame.disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.rect, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(canvas, frame.rect, previous, frame.rect.Min, draw.Src)
+		}
+
+		return composited, nil
+	}
+}
+
+// Converts a frame delay given as a fraction of a second to hundredths of a second
+func getFrameDelay(numerator, denominator int) int {
+	return int(math.Round(float64(numerator) * 100 / float64(denominator)))
+}
+
+// Converts the number of times an animation plays, where 0 means forever, to the LoopCount field of gif.GIF
+func getAnimationLoopCount(plays int) int {
+	if plays == 0 {
+		return 0
+	}
+	return getGifLoopCount(plays)
+}
+
+/*
+Splits an animated PNG into its frames. Each frame is rebuilt as a PNG file of its own out of the header chunks of
+the animation and its image data, so that it can be decoded by image/png. The returned bool is false if the file
+doesn't have an animation control chunk before its image data, as still PNG files don't
+*/
+func decodeAPNG(data []byte) (image.Rectangle, []encodedFrame, []int, int, bool, error) {
+
+	var (
+		header       []byte
+		sharedChunks bytes.Buffer
+		animated     bool
+		plays        int
+		frames       []encodedFrame
+		delays       []int
+		frameData    [][]byte
+		frameSizes   [][2]int
+		imageStarted bool
+		canvas       image.Rectangle
+	)
+
+	for offset := len(pngSignature); offset+12 <= len(data); {
+
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunkType := string(data[offset+4 : offset+8])
+		if length < 0 || offset+12+length > len(data) {
+			return canvas, nil, nil, 0, false, fmt.Errorf("png chunk %v is truncated", chunkType)
+		}
+		chunk := data[offset+8 : offset+8+length]
+		offset += 12 + length
+
+		switch chunkType {
+		case "IHDR":
+			if length != 13 {
+				return canvas, nil, nil, 0, false, fmt.Errorf("invalid png header")
+			}
+			header = chunk
+			canvas = image.Rect(0, 0, int(binary.BigEndian.Uint32(chunk)), int(binary.BigEndian.Uint32(chunk[4:])))
+
+		case "acTL":
+			if imageStarted {
+				continue
+			}
+			if length != 8 {
+				return canvas, nil, nil, 0, false, fmt.Errorf("invalid apng animation control chunk")
+			}
+			animated = true
+			plays = int(binary.BigEndian.Uint32(chunk[4:]))
+
+		case "fcTL":
+			if !animated {
+				continue
+			}
+			if length != 26 {
+				return canvas, nil, nil, 0, false, fmt.Errorf("invalid apng frame control chunk")
+			}
+
+			width := int(binary.BigEndian.Uint32(chunk[4:]))
+			height := int(binary.BigEndian.Uint32(chunk[8:]))
+			x := int(binary.BigEndian.Uint32(chunk[12:]))
+			y := int(binary.BigEndian.Uint32(chunk[16:]))
+			delayNumerator := int(binary.BigEndian.Uint16(chunk[20:]))
+			delayDenominator := int(binary.BigEndian.Uint16(chunk[22:]))
+
+			rect := image.Rect(x, y, x+width, y+height)
+			if width == 0 || height == 0 || !rect.In(canvas) {
+				return canvas, nil, nil, 0, false, fmt.Errorf("apng frame %d lies outside the image", len(frames)+1)
+			}
+
+			// A denominator of 0 means hundredths of a second
+			if delayDenominator == 0 {
+				delayDenominator = 100
+			}
+
+			frame := encodedFrame{
+				rect:     rect,
+				blend:    chunk[25] == 1,
+				disposal: gif.DisposalNone,
+			}
+			switch chunk[24] {
+			case 1:
+				frame.disposal = gif.DisposalBackground
+			case 2:
+				// The first frame has nothing to go back to, so it's cleared instead
+				if len(frames) == 0 {
+					frame.disposal = gif.DisposalBackground
+				} else {
+					frame.disposal = gif.DisposalPrevious
+				}
+			}
+
+			frames = append(frames, frame)
+			delays = append(delays, getFrameDelay(delayNumerator, delayDenominator))
+			frameData = append(frameData, nil)
+			frameSizes = append(frameSizes, [2]int{width, height})
+
+		case "IDAT":
+			imageStarted = true
+
+			// The default image is only part of the animation if a frame control chunk comes before it
+			if len(frames) > 0 {
+				frameData[len(frames)-1] = append(frameData[len(frames)-1], chunk...)
+			}
+
+		case "fdAT":
+			if len(frames) == 0 || length < 4 {
+				return canvas, nil, nil, 0, false, fmt.Errorf("invalid apng frame data chunk")
+			}
+			frameData[len(frames)-1] = append(frameData[len(frames)-1], chunk[4:]...)
+
+		case "IEND":
+			offset = len(data)
+
+		default:
+			// Chunks such as the palette and transparency apply to every frame
+			if !imageStarted {
+				writePNGChunk(&sharedChunks, chunkType, chunk)
+			}
+		}
+	}
+
+	if !animated {
+		return canvas, nil, nil, 0, false, nil
+	}
+	if header == nil {
+		return canvas, nil, nil, 0, false, fmt.Errorf("invalid png header")
+	}
+
+	for i := range frames {
+		if len(frameData[i]) == 0 {
+			return canvas, nil, nil, 0, false, fmt.Errorf("apng frame %d has no image data", i+1)
+		}
+
+		var file bytes.Buffer
+		file.Write(pngSignature)
+
+		frameHeader := append([]byte(nil), header...)
+		binary.BigEndian.PutUint32(frameHeader, uint32(frameSizes[i][0]))
+		binary.BigEndian.PutUint32(frameHeader[4:], uint32(frameSizes[i][1]))
+		writePNGChunk(&file, "IHDR", frameHeader)
+
+		file.Write(sharedChunks.Bytes())
+		writePNGChunk(&file, "IDAT", frameData[i])
+		writePNGChunk(&file, "IEND", nil)
+
+		encoded := file.Bytes()
+		if _, err := png.DecodeConfig(bytes.NewReader(encoded)); err != nil {
+			return canvas, nil, nil, 0, false, fmt.Errorf("can't decode apng frame %d: %v", i+1, err)
+		}
+
+		frames[i].decode = func() (image.Image, error) {
+			return png.Decode(bytes.NewReader(encoded))
+		}
+	}
+
+	return canvas, frames, delays, getAnimationLoopCount(plays), true, nil
+}
+
+// Writes a PNG chunk of the passed type and contents, along with its length and checksum
+func writePNGChunk(buffer *bytes.Buffer, chunkType string, chunk []byte) {
+
+	binary.Write(buffer, binary.BigEndian, uint32(len(chunk)))
+
+	checksum := crc32.NewIEEE()
+	checksum.Write([]byte(chunkType))
+	checksum.Write(chunk)
+
+	buffer.WriteString(chunkType)
+	buffer.Write(chunk)
+	binary.Write(buffer, binary.BigEndian, checksum.Sum32())
+}
+
+/*
+Splits an animated WebP file into its frames. Each frame is rebuilt as a WebP file of its own out of its image
+data, and its alpha data if there is any, so that it can be decoded by golang.org/x/image/webp. The returned bool
+is false if the file's extended header doesn't have the animation flag set
+*/
+func decodeAnimatedWebP(data []byte) (image.Rectangle, []encodedFrame, []int, int, bool, error) {
+
+	var (
+		canvas   image.Rectangle
+		animated bool
+		plays    int
+		frames   []encodedFrame
+		delays   []int
+	)
+
+	chunks, err := readWebPChunks(data[12:])
+	if err != nil {
+		return canvas, nil, nil, 0, false, err
+	}
+
+	for _, chunk := range chunks {
+		switch chunk.fourCC {
+		case "VP8X":
+			if len(chunk.data) < 10 {
+				return canvas, nil, nil, 0, false, fmt.Errorf("invalid webp header")
+			}
+			animated = chunk.data[0]&0x02 != 0
+			canvas = image.Rect(0, 0, readUint24(chunk.data[4:])+1, readUint24(chunk.data[7:])+1)
+
+		case "ANIM":
+			if len(chunk.data) < 6 {
+				return canvas, nil, nil, 0, false, fmt.Errorf("invalid webp animation chunk")
+			}
+			plays = int(binary.LittleEndian.Uint16(chunk.data[4:]))
+
+		case "ANMF":
+			if !animated {
+				continue
+			}
+			if len(chunk.data) < 16 {
+				return canvas, nil, nil, 0, false, fmt.Errorf("invalid webp animation frame")
+			}
+
+			x := readUint24(chunk.data) * 2
+			y := readUint24(chunk.data[3:]) * 2
+			width := readUint24(chunk.data[6:]) + 1
+			height := readUint24(chunk.data[9:]) + 1
+			duration := readUint24(chunk.data[12:])
+			flags := chunk.data[15]
+
+			rect := image.Rect(x, y, x+width, y+height)
+			if !rect.In(canvas) {
+				return canvas, nil, nil, 0, false, fmt.Errorf("webp frame %d lies outside the image", len(frames)+1)
+			}
+
+			encoded, err := rebuildWebPFrame(chunk.data[16:], width, height)
+			if err != nil {
+				return canvas, nil, nil, 0, false, fmt.Errorf("can't decode webp frame %d: %v", len(frames)+1, err)
+			}
+			if _, err := webp.DecodeConfig(bytes.NewReader(encoded)); err != nil {
+				return canvas, nil, nil, 0, false, fmt.Errorf("can't decode webp frame %d: %v", len(frames)+1, err)
+			}
+
+			frame := encodedFrame{
+				rect:     rect,
+				blend:    flags&0x02 == 0,
+				disposal: gif.DisposalNone,
+				decode: func() (image.Image, error) {
+					return webp.Decode(bytes.NewReader(encoded))
+				},
+			}
+			if flags&0x01 != 0 {
+				frame.disposal = gif.DisposalBackground
+			}
+
+			frames = append(frames, frame)
+			delays = append(delays, getFrameDelay(duration, 1000))
+		}
+	}
+
+	if !animated {
+		return canvas, nil, nil, 0, false, nil
+	}
+
+	return canvas, frames, delays, getAnimationLoopCount(plays), true, nil
+}
+
+// Chunk of a RIFF file, such as a WebP file
+type riffChunk struct {
+	fourCC string
+	data   []byte
+}
+
+// Splits the passed data into RIFF chunks, which are padded to an even length
+func readWebPChunks(data []byte) ([]riffChunk, error) {
+
+	var chunks []riffChunk
+
+	for offset := 0; offset+8 <= len(data); {
+		fourCC := string(data[offset : offset+4])
+		length := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		if length < 0 || offset+8+length > len(data) {
+			return nil, fmt.Errorf("webp chunk %v is truncated", fourCC)
+		}
+
+		chunks = append(chunks, riffChunk{fourCC, data[offset+8 : offset+8+length]})
+		offset += 8 + length + length%2
+	}
+
+	return chunks, nil
+}
+
+/*
+Builds a WebP file out of the chunks of an animation frame. Frames with a separate alpha chunk need an extended
+header to go with it, while the rest are stored as simple WebP files
+*/
+func rebuildWebPFrame(frameData []byte, width, height int) ([]byte, error) {
+
+	chunks, err := readWebPChunks(frameData)
+	if err != nil {
+		return nil, err
+	}
+
+	var alpha, bitstream *riffChunk
+	for i := range chunks {
+		switch chunks[i].fourCC {
+		case "ALPH":
+			alpha = &chunks[i]
+		case "VP8 ", "VP8L":
+			bitstream = &chunks[i]
+		}
+	}
+	if bitstream == nil {
+		return nil, fmt.Errorf("frame has no image data")
+	}
+
+	var body bytes.Buffer
+	body.Write(webpHeader)
+
+	// Lossless frames carry their own alpha channel
+	if alpha != nil && bitstream.fourCC == "VP8 " {
+		header := make([]byte, 10)
+		header[0] = 0x10
+		putUint24(header[4:], width-1)
+		putUint24(header[7:], height-1)
+
+		writeRIFFChunk(&body, "VP8X", header)
+		writeRIFFChunk(&body, alpha.fourCC, alpha.data)
+	}
+	writeRIFFChunk(&body, bitstream.fourCC, bitstream.data)
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+
+	return file.Bytes(), nil
+}
+
+// Writes a RIFF chunk of the passed type and contents, padded to an even length
+func writeRIFFChunk(buffer *bytes.Buffer, fourCC string, chunk []byte) {
+
+	buffer.WriteString(fourCC)
+	binary.Write(buffer, binary.LittleEndian, uint32(len(chunk)))
+	buffer.Write(chunk)
+
+	if len(chunk)%2 != 0 {
+		buffer.WriteByte(0)
+	}
+}
+
+// Reads a 24-bit little-endian integer, which WebP uses for frame dimensions and durations
+func readUint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// Writes a 24-bit little-endian integer
+func putUint24(b []byte, v int) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
@@ -0,0 +1,454 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+var (
+	testRed   = color.NRGBA{255, 0, 0, 255}
+	testGreen = color.NRGBA{0, 255, 0, 255}
+	testBlue  = color.NRGBA{0, 0, 255, 255}
+)
+
+// Frame of an animation built by the tests, filled with a single color
+type testFrame struct {
+	rect    image.Rectangle
+	fill    color.NRGBA
+	delay   int // Hundredths of a second
+	dispose byte
+	blend   byte
+	noFctl  bool // Only for the first APNG frame, which is then left out of the animation
+}
+
+func solidImage(width, height int, fill color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+	return img
+}
+
+// Builds an APNG file out of frames encoded by image/png, using the first frame as the default image
+func buildAPNG(t *testing.T, width, height, plays int, frames []testFrame) []byte {
+	t.Helper()
+
+	var file bytes.Buffer
+	file.Write(pngSignature)
+
+	sequence := uint32(0)
+	animatedFrames := 0
+	for _, frame := range frames {
+		if !frame.noFctl {
+			animatedFrames++
+		}
+	}
+
+	for i, frame := range frames {
+		// Frames are all opaque, so that image/png encodes them with the same color type as the header
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, solidImage(frame.rect.Dx(), frame.rect.Dy(), frame.fill)); err != nil {
+			t.Fatal(err)
+		}
+
+		var idat [][]byte
+		data := encoded.Bytes()
+		for offset := len(pngSignature); offset < len(data); {
+			length := int(binary.BigEndian.Uint32(data[offset:]))
+			chunkType := string(data[offset+4 : offset+8])
+			chunk := data[offset+8 : offset+8+length]
+			offset += 12 + length
+
+			if i == 0 && chunkType == "IHDR" {
+				header := append([]byte(nil), chunk...)
+				binary.BigEndian.PutUint32(header, uint32(width))
+				binary.BigEndian.PutUint32(header[4:], uint32(height))
+				writePNGChunk(&file, "IHDR", header)
+
+				control := make([]byte, 8)
+				binary.BigEndian.PutUint32(control, uint32(animatedFrames))
+				binary.BigEndian.PutUint32(control[4:], uint32(plays))
+				writePNGChunk(&file, "acTL", control)
+			}
+			if chunkType == "IDAT" {
+				idat = append(idat, chunk)
+			}
+		}
+
+		if !frame.noFctl {
+			control := make([]byte, 26)
+			binary.BigEndian.PutUint32(control, sequence)
+			binary.BigEndian.PutUint32(control[4:], uint32(frame.rect.Dx()))
+			binary.BigEndian.PutUint32(control[8:], uint32(frame.rect.Dy()))
+			binary.BigEndian.PutUint32(control[12:], uint32(frame.rect.Min.X))
+			binary.BigEndian.PutUint32(control[16:], uint32(frame.rect.Min.Y))
+			binary.BigEndian.PutUint16(control[20:], uint16(frame.delay))
+			binary.BigEndian.PutUint16(control[22:], 100)
+			control[24], control[25] = frame.dispose, frame.blend
+			writePNGChunk(&file, "fcTL", control)
+			sequence++
+		}
+
+		for _, chunk := range idat {
+			if i == 0 {
+				writePNGChunk(&file, "IDAT", chunk)
+				continue
+			}
+			frameData := make([]byte, 4, 4+len(chunk))
+			binary.BigEndian.PutUint32(frameData, sequence)
+			writePNGChunk(&file, "fdAT", append(frameData, chunk...))
+			sequence++
+		}
+	}
+
+	writePNGChunk(&file, "IEND", nil)
+	return file.Bytes()
+}
+
+// Writes bits to a byte slice, least significant bit first, as VP8L bitstreams are laid out
+type bitWriter struct {
+	data  []byte
+	nBits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.nBits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte((value>>i)&1) << (w.nBits % 8)
+		w.nBits++
+	}
+}
+
+/*
+Encodes a lossless VP8L bitstream of a single color. Every prefix code holds a single symbol, which takes no bits
+to write, so the image data itself is empty
+*/
+func encodeSolidVP8L(width, height int, fill color.NRGBA) []byte {
+
+	w := &bitWriter{}
+	w.write(0x2f, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(1, 1) // Alpha is used
+	w.write(0, 3) // Version
+
+	w.write(0, 1) // No transforms
+	w.write(0, 1) // No color cache
+	w.write(0, 1) // No meta prefix codes
+
+	for _, symbol := range []uint8{fill.G, fill.R, fill.B, fill.A, 0} {
+		w.write(1, 1) // Simple code
+		w.write(0, 1) // One symbol
+		w.write(1, 1) // Symbol takes 8 bits
+		w.write(uint32(symbol), 8)
+	}
+
+	return w.data
+}
+
+// Builds an animated WebP file with lossless frames, since there's no lossy encoder to make frames with
+func buildAnimatedWebP(width, height, plays int, frames []testFrame) []byte {
+
+	var body bytes.Buffer
+	body.Write(webpHeader)
+
+	header := make([]byte, 10)
+	header[0] = 0x12
+	putUint24(header[4:], width-1)
+	putUint24(header[7:], height-1)
+	writeRIFFChunk(&body, "VP8X", header)
+
+	anim := make([]byte, 6)
+	binary.LittleEndian.PutUint16(anim[4:], uint16(plays))
+	writeRIFFChunk(&body, "ANIM", anim)
+
+	for _, frame := range frames {
+		var frameData bytes.Buffer
+
+		control := make([]byte, 16)
+		putUint24(control, frame.rect.Min.X/2)
+		putUint24(control[3:], frame.rect.Min.Y/2)
+		putUint24(control[6:], frame.rect.Dx()-1)
+		putUint24(control[9:], frame.rect.Dy()-1)
+		putUint24(control[12:], frame.delay*10)
+		if frame.blend == 0 {
+			control[15] |= 0x02
+		}
+		if frame.dispose == 1 {
+			control[15] |= 0x01
+		}
+		frameData.Write(control)
+
+		writeRIFFChunk(&frameData, "VP8L", encodeSolidVP8L(frame.rect.Dx(), frame.rect.Dy(), frame.fill))
+		writeRIFFChunk(&body, "ANMF", frameData.Bytes())
+	}
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+// Draws every frame of the passed animation and returns the color at the passed point of each
+func colorsAt(t *testing.T, anim animation, point image.Point) []color.NRGBA {
+	t.Helper()
+
+	var colors []color.NRGBA
+
+	nextFrame := anim.newCompositor()
+	for i := 0; i < anim.frameCount; i++ {
+		frame, err := nextFrame(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		colors = append(colors, color.NRGBAModel.Convert(frame.At(point.X, point.Y)).(color.NRGBA))
+	}
+	return colors
+}
+
+func TestDecodeAPNG(t *testing.T) {
+
+	data := buildAPNG(t, 8, 8, 3, []testFrame{
+		{rect: image.Rect(0, 0, 8, 8), fill: testRed, delay: 10},
+		{rect: image.Rect(2, 2, 6, 6), fill: testGreen, delay: 20, blend: 1, dispose: 1},
+		{rect: image.Rect(4, 4, 8, 8), fill: testBlue, delay: 5, blend: 1, dispose: 2},
+		{rect: image.Rect(0, 0, 2, 2), fill: testGreen, delay: 0, blend: 1},
+	})
+
+	anim, animated, err := decodeAnimatedImage(data)
+	if err != nil || !animated {
+		t.Fatalf("decodeAnimatedImage() = %v, %v", animated, err)
+	}
+
+	if anim.frameCount != 4 {
+		t.Fatalf("frameCount = %d, want 4", anim.frameCount)
+	}
+	if want := []int{10, 20, 5, 0}; !equalInts(anim.delays, want) {
+		t.Errorf("delays = %v, want %v", anim.delays, want)
+	}
+	// 3 plays repeat twice
+	if anim.loopCount != 2 {
+		t.Errorf("loopCount = %d, want 2", anim.loopCount)
+	}
+
+	// Frame 1 is cleared to transparency after it's shown, and frame 2 is restored to what was under it
+	checkColors(t, "center", colorsAt(t, anim, image.Pt(3, 3)), []color.NRGBA{testRed, testGreen, {}, {}})
+	checkColors(t, "corner", colorsAt(t, anim, image.Pt(5, 5)), []color.NRGBA{testRed, testGreen, testBlue, {}})
+	checkColors(t, "origin", colorsAt(t, anim, image.Pt(0, 0)), []color.NRGBA{testRed, testRed, testRed, testGreen})
+}
+
+func TestDecodeAPNGDefaultImage(t *testing.T) {
+
+	// A default image without a frame control chunk isn't part of the animation
+	data := buildAPNG(t, 4, 4, 0, []testFrame{
+		{rect: image.Rect(0, 0, 4, 4), fill: testRed, noFctl: true},
+		{rect: image.Rect(0, 0, 4, 4), fill: testGreen, delay: 7},
+	})
+
+	anim, animated, err := decodeAnimatedImage(data)
+	if err != nil || !animated {
+		t.Fatalf("decodeAnimatedImage() = %v, %v", animated, err)
+	}
+	if anim.frameCount != 1 || anim.loopCount != 0 {
+		t.Errorf("frameCount, loopCount = %d, %d, want 1, 0", anim.frameCount, anim.loopCount)
+	}
+	checkColors(t, "frame", colorsAt(t, anim, image.Pt(1, 1)), []color.NRGBA{testGreen})
+}
+
+func TestDecodeStillPNG(t *testing.T) {
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, solidImage(4, 4, testRed)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, animated, err := decodeAnimatedImage(encoded.Bytes()); animated || err != nil {
+		t.Errorf("decodeAnimatedImage() = %v, %v, want false, nil", animated, err)
+	}
+
+	if _, animated, err := decodeAnimatedImage([]byte("not an image")); animated || err != nil {
+		t.Errorf("decodeAnimatedImage() = %v, %v, want false, nil", animated, err)
+	}
+}
+
+func TestDecodeTruncatedAPNG(t *testing.T) {
+
+	data := buildAPNG(t, 4, 4, 0, []testFrame{
+		{rect: image.Rect(0, 0, 4, 4), fill: testRed},
+		{rect: image.Rect(0, 0, 4, 4), fill: testGreen},
+	})
+
+	if _, _, err := decodeAnimatedImage(data[:len(data)-20]); err == nil {
+		t.Error("decodeAnimatedImage() of a truncated file didn't fail")
+	}
+}
+
+func TestCompositorKeepsFramesUnderDisposePrevious(t *testing.T) {
+
+	// Frame 2 replaces the whole canvas and is disposed to the previous one, so frame 1 must still be drawn
+	// under it, even when only frames 0 and 3 are kept
+	data := buildAPNG(t, 4, 4, 0, []testFrame{
+		{rect: image.Rect(0, 0, 4, 4), fill: testRed},
+		{rect: image.Rect(0, 0, 4, 4), fill: testGreen},
+		{rect: image.Rect(0, 0, 4, 4), fill: testBlue, dispose: 2},
+		{rect: image.Rect(0, 0, 1, 1), fill: testRed, blend: 1},
+	})
+
+	anim, _, err := decodeAnimatedImage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nextFrame := anim.newCompositor()
+	var last image.Image
+	for i := 0; i < anim.frameCount; i++ {
+		frame, err := nextFrame(i == 0 || i == 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame != nil {
+			last = frame
+		}
+	}
+
+	if got := color.NRGBAModel.Convert(last.At(2, 2)); got != testGreen {
+		t.Errorf("color of last frame = %v, want %v", got, testGreen)
+	}
+}
+
+func TestDecodeAnimatedWebP(t *testing.T) {
+
+	data := buildAnimatedWebP(8, 8, 2, []testFrame{
+		{rect: image.Rect(0, 0, 8, 8), fill: testRed, delay: 10},
+		{rect: image.Rect(2, 2, 6, 6), fill: testGreen, delay: 4, blend: 1, dispose: 1},
+		{rect: image.Rect(4, 4, 8, 8), fill: color.NRGBA{}, delay: 25},
+	})
+
+	anim, animated, err := decodeAnimatedImage(data)
+	if err != nil || !animated {
+		t.Fatalf("decodeAnimatedImage() = %v, %v", animated, err)
+	}
+
+	if want := []int{10, 4, 25}; !equalInts(anim.delays, want) {
+		t.Errorf("delays = %v, want %v", anim.delays, want)
+	}
+	if anim.loopCount != 1 {
+		t.Errorf("loopCount = %d, want 1", anim.loopCount)
+	}
+
+	// The last frame isn't blended, so its transparent pixels replace the ones under it
+	checkColors(t, "center", colorsAt(t, anim, image.Pt(3, 3)), []color.NRGBA{testRed, testGreen, {}})
+	checkColors(t, "corner", colorsAt(t, anim, image.Pt(5, 5)), []color.NRGBA{testRed, testGreen, {}})
+	checkColors(t, "origin", colorsAt(t, anim, image.Pt(0, 0)), []color.NRGBA{testRed, testRed, testRed})
+}
+
+func TestDecodeStillWebP(t *testing.T) {
+
+	var body bytes.Buffer
+	body.Write(webpHeader)
+	writeRIFFChunk(&body, "VP8L", encodeSolidVP8L(4, 4, testRed))
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+
+	if _, animated, err := decodeAnimatedImage(file.Bytes()); animated || err != nil {
+		t.Errorf("decodeAnimatedImage() = %v, %v, want false, nil", animated, err)
+	}
+}
+
+func TestDecodeWebPFrameOutsideCanvas(t *testing.T) {
+
+	data := buildAnimatedWebP(4, 4, 0, []testFrame{
+		{rect: image.Rect(2, 2, 6, 6), fill: testRed},
+	})
+
+	if _, _, err := decodeAnimatedImage(data); err == nil {
+		t.Error("decodeAnimatedImage() of a frame outside the canvas didn't fail")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkColors(t *testing.T, name string, got, want []color.NRGBA) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%v: got %d frames, want %d", name, len(got), len(want))
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%v: frame %d = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestIsAnimatedImage(t *testing.T) {
+
+	var stillPNG bytes.Buffer
+	if err := png.Encode(&stillPNG, solidImage(4, 4, testRed)); err != nil {
+		t.Fatal(err)
+	}
+
+	animatedPNG := buildAPNG(t, 4, 4, 0, []testFrame{
+		{rect: image.Rect(0, 0, 4, 4), fill: testRed},
+		{rect: image.Rect(0, 0, 4, 4), fill: testGreen},
+	})
+	animatedWebP := buildAnimatedWebP(4, 4, 0, []testFrame{
+		{rect: image.Rect(0, 0, 4, 4), fill: testRed},
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"still png", stillPNG.Bytes(), false},
+		{"animated png", animatedPNG, true},
+		{"animated webp", animatedWebP, true},
+		{"animated png header only", animatedPNG[:60], true},
+		{"short data", []byte("RIFF"), false},
+		{"other data", []byte("GIF89a and some more bytes"), false},
+	}
+
+	for _, test := range tests {
+		if got, err := isAnimatedImage(bytes.NewReader(test.data)); got != test.want || err != nil {
+			t.Errorf("%v: isAnimatedImage() = %v, %v, want %v, nil", test.name, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/TheZoraiz/ascii-image-converter/aic_package/winsize"
+	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
+)
+
+// Limits and step of the playback speed of the interactive player
+const (
+	minPlayerSpeed  = 0.25
+	maxPlayerSpeed  = 4
+	playerSpeedStep = 0.25
+)
+
+/*
+Reads keys pressed in the terminal from input and sends them to the passed channel, with arrow keys named "left"
+and "right". The channel is closed once input can't be read anymore. Closing done stops sending keys, and closing
+input stops a read that's waiting for one
+*/
+func readKeys(input io.Reader, keys chan<- string, done <-chan struct{}) {
+
+	defer close(keys)
+
+	buffer := make([]byte, 64)
+
+	send := func(key string) bool {
+		select {
+		case keys <- key:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	for {
+		n, err := input.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		pressed := string(buffer[:n])
+
+		for len(pressed) > 0 {
+			key := pressed[:1]
+
+			switch {
+			case strings.HasPrefix(pressed, "\x1b[C"), strings.HasPrefix(pressed, "\x1bOC"):
+				key = "right"
+				pressed = pressed[3:]
+
+			case strings.HasPrefix(pressed, "\x1b[D"), strings.HasPrefix(pressed, "\x1bOD"):
+				key = "left"
+				pressed = pressed[3:]
+
+			default:
+				pressed = pressed[1:]
+			}
+
+			if !send(key) {
+				return
+			}
+		}
+	}
+}
+
+// Cuts the passed string short so that it takes up less than the passed number of terminal columns
+func truncateToColumns(s string, columns int) string {
+
+	used := 0
+	for i, char := range s {
+		used += imgManip.DisplayWidth(string(char))
+		if used >= columns {
+			return s[:i]
+		}
+	}
+
+	return s
+}
+
+/*
+Plays the passed frames of ascii art in the terminal while reading keys from it. Space pauses or resumes, the left
+and right arrow keys step through frames, + and - change the speed, s passes the current frame's index to
+saveFrame and q quits. A status line below the frames shows the frame number, its delay and the speed.
+
+Unlike playAsciiFrames(), playback pauses on the last frame after playing playCount times instead of returning
+*/
+func playAsciiFramesInteractively(asciiSets [][][]imgManip.AsciiChar, delays []int, playCount int, colored bool, saveFrame func(index int) error) error {
+
+	if len(asciiSets) == 0 {
+		return nil
+	}
+
+	frames := make([][][]terminalCell, len(asciiSets))
+	for i, asciiSet := range asciiSets {
+		frames[i] = getTerminalCells(asciiSet, colored)
+	}
+
+	player := newTerminalPlayer()
+	defer player.stop()
+
+	if err := player.enableRawInput(); err != nil {
+		return fmt.Errorf("can't read keys from terminal: %v", err)
+	}
+
+	input, err := openCancelableStdin()
+	if err != nil {
+		return fmt.Errorf("can't read keys from terminal: %v", err)
+	}
+	defer input.Close()
+
+	keys := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+
+	go readKeys(input, keys, done)
+
+	var (
+		index       = 0
+		timesPlayed = 0
+		paused      = false
+		speed       = 1.0
+		message     = ""
+	)
+
+	getFrameDuration := func(i int) time.Duration {
+		return time.Duration(float64(delays[i]) * float64(time.Second) / 100 / speed)
+	}
+
+	deadline := time.Now().Add(getFrameDuration(index))
+
+	for {
+		player.drawFrame(frames[index])
+
+		state := "Playing"
+		if paused {
+			state = "Paused"
+		}
+		if message == "" {
+			message = "[space] pause  [left/right] step  [+/-] speed  [s] save  [q] quit"
+		}
+
+		// A wrapping status line would scroll the frame up on the last row of the terminal
+		status := fmt.Sprintf("Frame %d/%d  %dms  %gx  %v  %v", index+1, len(frames), delays[index]*10, speed, state, message)
+		if columns, _, err := winsize.GetTerminalSize(); err == nil && columns > 0 {
+			status = truncateToColumns(status, columns)
+		}
+		player.drawStatus(status)
+
+		var (
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		if !paused {
+			timer = time.NewTimer(time.Until(deadline))
+			timeout = timer.C
+		}
+
+		select {
+		case <-timeout:
+			if index == len(frames)-1 {
+				timesPlayed++
+
+				if playCount != 0 && timesPlayed >= playCount {
+					paused = true
+					continue
+				}
+			}
+
+			index = (index + 1) % len(frames)
+
+			// Each frame ends a delay after the previous one did, unless the terminal fell behind
+			deadline = deadline.Add(getFrameDuration(index))
+			if now := time.Now(); deadline.Before(now) {
+				deadline = now.Add(getFrameDuration(index))
+			}
+
+		case key, ok := <-keys:
+			if timer != nil {
+				timer.Stop()
+			}
+
+			if !ok {
+				return nil
+			}
+
+			message = ""
+
+			switch key {
+			case " ":
+				paused = !paused
+
+				if !paused {
+					// Start over once the set number of plays is over
+					if playCount != 0 && timesPlayed >= playCount {
+						index = 0
+						timesPlayed = 0
+					}
+					deadline = time.Now().Add(getFrameDuration(index))
+				}
+
+			case "right":
+				paused = true
+				index = (index + 1) % len(frames)
+
+			case "left":
+				paused = true
+				index = (index - 1 + len(frames)) % len(frames)
+
+			case "+", "=", "-", "_":
+				previousSpeed := speed
+
+				if (key == "+" || key == "=") && speed < maxPlayerSpeed {
+					speed += playerSpeedStep
+				} else if (key == "-" || key == "_") && speed > minPlayerSpeed {
+					speed -= playerSpeedStep
+				}
+
+				// The rest of the current frame's delay is played at the new speed too
+				if !paused {
+					deadline = time.Now().Add(time.Duration(float64(time.Until(deadline)) * previousSpeed / speed))
+				}
+
+			case "s", "S":
+				if err := saveFrame(index); err != nil {
+					message = fmt.Sprintf("Can't save frame: %v", err)
+				} else {
+					message = fmt.Sprintf("Saved frame %d", index+1)
+				}
+
+			// Ctrl+C is read as a key in raw mode, instead of interrupting the process
+			case "q", "Q", "\x03":
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import "testing"
+
+func TestTruncateToColumns(t *testing.T) {
+
+	tests := []struct {
+		status  string
+		columns int
+		want    string
+	}{
+		{"Frame 1/2", 20, "Frame 1/2"},
+		{"Frame 1/2", 9, "Frame 1/"},
+		// Multi-byte characters are never cut in half
+		{"Saved é.png", 8, "Saved é"},
+		// Wide characters take up two columns each
+		{"Saved 画像.png", 10, "Saved 画"},
+		{"Saved 画像.png", 11, "Saved 画像"},
+	}
+
+	for _, test := range tests {
+		if got := truncateToColumns(test.status, test.columns); got != test.want {
+			t.Errorf("truncateToColumns(%q, %d) = %q, want %q", test.status, test.columns, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// Input of the interactive player, whose reads return as soon as it's closed
+type cancelableInput struct {
+	*os.File
+
+	// Descriptor the input was duplicated from, which is put back in blocking mode once it's closed
+	fd int
+}
+
+// Returns stdin as a reader that stops reading from it as soon as it's closed, while stdin itself is left open
+func openCancelableStdin() (io.ReadCloser, error) {
+	return openCancelableInput(syscall.Stdin)
+}
+
+/*
+Duplicates the passed descriptor and puts it in non-blocking mode, so that it's read through the runtime's poller
+and closing it interrupts a pending read. Otherwise a read would keep waiting for a key after playback ends, and
+take the first key meant for whatever reads the terminal next
+*/
+
+func openCancelableInput(fd int) (io.ReadCloser, error) {
+
+	inputFd, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.SetNonblock(inputFd, true); err != nil {
+		syscall.Close(inputFd)
+		return nil, err
+	}
+
+	return &cancelableInput{os.NewFile(uintptr(inputFd), "stdin"), fd}, nil
+}
+
+func (input *cancelableInput) Close() error {
+
+	err := input.File.Close()
+
+	// Both descriptors share the blocking mode, which the shell expects to be restored
+	syscall.SetNonblock(input.fd, false)
+
+	return err
+}
@@ -0,0 +1,84 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCancelableInput(t *testing.T) {
+
+	var pipe [2]int
+	if err := syscall.Pipe(pipe[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(pipe[0])
+	defer syscall.Close(pipe[1])
+
+	input, err := openCancelableInput(pipe[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make(chan string)
+	done := make(chan struct{})
+	go readKeys(input, keys, done)
+
+	syscall.Write(pipe[1], []byte("\x1b[Cq"))
+	for _, want := range []string{"right", "q"} {
+		if key := <-keys; key != want {
+			t.Fatalf("key = %q, want %q", key, want)
+		}
+	}
+
+	// Closing the input stops the read that's waiting for the next key, which is given time to start
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	if err := input.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case _, ok := <-keys:
+		if ok {
+			t.Fatal("got a key after closing the input")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closing the input didn't stop the pending read")
+	}
+
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(pipe[0]), syscall.F_GETFL, 0)
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	if flags&syscall.O_NONBLOCK != 0 {
+		t.Error("input is still in non-blocking mode after closing")
+	}
+
+	// Keys pressed afterwards are left for whatever reads the terminal next
+	syscall.Write(pipe[1], []byte("a"))
+
+	buffer := make([]byte, 4)
+	if n, err := syscall.Read(pipe[0], buffer); err != nil || string(buffer[:n]) != "a" {
+		t.Errorf("read after closing = %q, %v, want \"a\"", buffer[:n], err)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Input of the interactive player, whose reads return as soon as it's closed
+type cancelableInput struct {
+	mutex  sync.Mutex
+	closed bool
+
+	// Closed once the latest read of stdin returns, or nil if it hasn't been read yet
+	reading chan struct{}
+}
+
+// Returns stdin as a reader that stops reading from it as soon as it's closed, while stdin itself is left open
+func openCancelableStdin() (io.ReadCloser, error) {
+	return &cancelableInput{}, nil
+}
+
+func (input *cancelableInput) Read(p []byte) (int, error) {
+
+	input.mutex.Lock()
+	if input.closed {
+		input.mutex.Unlock()
+		return 0, os.ErrClosed
+	}
+	reading := make(chan struct{})
+	input.reading = reading
+	input.mutex.Unlock()
+
+	defer close(reading)
+
+	return os.Stdin.Read(p)
+}
+
+/*
+Cancels a pending read of the console, so that it doesn't keep waiting for a key after playback ends and take the
+first key meant for whatever reads the terminal next. A read that hasn't reached the console yet would miss the
+cancellation, so it's repeated for a while until the read returns
+*/
+
+func (input *cancelableInput) Close() error {
+
+	input.mutex.Lock()
+	input.closed = true
+	reading := input.reading
+	input.mutex.Unlock()
+
+	if reading == nil {
+		return nil
+	}
+
+	handle := syscall.Handle(os.Stdin.Fd())
+
+	for attempt := 0; attempt < 50; attempt++ {
+		syscall.CancelIoEx(handle, nil)
+
+		select {
+		case <-reading:
+			return nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,311 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
+)
+
+// Frame of an animation after it's converted by streamAnimationFrames()
+type streamedFrame struct {
+	index    int
+	asciiSet [][]imgManip.AsciiChar
+
+	// Frame of the saved gif, if one is rendered
+	rendered *image.Paletted
+
+	err error
+}
+
+/*
+Same as the rest of convertAnimation(), but frames are saved and played as soon as they're converted, instead of
+keeping all of them in memory. Since frames aren't kept, each loop of terminal playback converts them again.
+
+Reversing, ping-ponging, generating a global palette and interactive playback need all frames at once, so they
+can't be combined with streaming
+*/
+func streamAnimation(anim animation, frameIndices, frameDelays []int, loopCount int, firstGifFrame image.Image, cropRect image.Rectangle, inputPath, urlImgName string) error {
+
+	if gifReverse || gifPingPong || globalGifPalette || interactive {
+		return fmt.Errorf("gifs can't be streamed while reversing, ping-ponging, generating a global palette or playing interactively")
+	}
+
+	// Shared equalization histograms need every frame, so they're computed in a pass of their own
+	if adjustments.Equalization != "" && equalizeAcrossFrames {
+		histograms, err := computeSharedHistograms(anim, frameIndices, cropRect)
+		if err != nil {
+			return err
+		}
+		adjustments.EqualizationHistograms = histograms
+	}
+
+	var (
+		fullPathName string
+		gifFile      *os.File
+		gifWriter    *bufio.Writer
+		streamWriter *gifStreamWriter
+		optimizer    *gifFrameOptimizer
+		render       func(asciiSet [][]imgManip.AsciiChar) (*image.Paletted, error)
+	)
+
+	if saveGifPath != "" {
+		saveFileName, err := createSaveFileName(inputPath, urlImgName, "-ascii-art.gif")
+		if err != nil {
+			return err
+		}
+
+		fullPathName, err = getFullSavePath(saveFileName, saveGifPath)
+		if err != nil {
+			return fmt.Errorf("can't save file: %v", err)
+		}
+
+		gifFile, err = os.OpenFile(fullPathName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return fmt.Errorf("can't save file: %v", err)
+		}
+		defer gifFile.Close()
+
+		gifWriter = bufio.NewWriter(gifFile)
+		streamWriter = newGifStreamWriter(gifWriter, loopCount)
+
+		// Only the changes between frames are stored, which keeps ascii art gifs small
+		optimizer = newGifFrameOptimizer(streamWriter.writeFrame)
+
+		paletteColors, drawer := getGifQuantization()
+
+		render = func(asciiSet [][]imgManip.AsciiChar) (*image.Paletted, error) {
+			tempImg, err := createGifFrameToSave(asciiSet, firstGifFrame, colored || grayscale)
+			if err != nil {
+				return nil, err
+			}
+
+			framePalette := imgManip.GeneratePalette([]image.Image{tempImg}, gifPalette, paletteColors)
+			return quantizeFrame(tempImg, framePalette, drawer), nil
+		}
+	}
+
+	var player *terminalPlayer
+	if !onlySave {
+		player = newTerminalPlayer()
+		defer player.stop()
+	} else {
+		fmt.Printf("Saving gif... 0%%\r")
+	}
+
+	var (
+		playCount    = getTerminalPlayCount(loopCount)
+		lastAsciiSet [][]imgManip.AsciiChar
+		deadline     time.Time
+	)
+
+	handleFrame := func(frame streamedFrame) error {
+
+		if frame.rendered != nil {
+			if err := optimizer.add(frame.rendered, frameDelays[frame.index]); err != nil {
+				return fmt.Errorf("can't save file: %v", err)
+			}
+		}
+
+		if player == nil {
+			percentage := int((float64(frame.index+1) / float64(len(frameIndices))) * 100)
+			fmt.Printf("Saving gif... " + strconv.Itoa(percentage) + "%%\r")
+			return nil
+		}
+
+		player.drawFrame(getTerminalCells(frame.asciiSet, colored || grayscale))
+		deadline = waitForNextFrame(deadline, frameDelays[frame.index])
+		lastAsciiSet = frame.asciiSet
+
+		return nil
+	}
+
+	// The gif is saved during the first pass, while the rest only play frames
+	for timesPlayed := 0; timesPlayed == 0 || (player != nil && (playCount == 0 || timesPlayed < playCount)); timesPlayed++ {
+
+		deadline = time.Now()
+
+		if err := streamAnimationFrames(anim, frameIndices, cropRect, render, handleFrame); err != nil {
+			return err
+		}
+
+		if optimizer != nil {
+			err := optimizer.flush()
+			if err == nil {
+				err = streamWriter.close()
+			}
+			if err == nil {
+				err = gifWriter.Flush()
+			}
+			if err != nil {
+				return fmt.Errorf("can't save file: %v", err)
+			}
+
+			optimizer = nil
+			render = nil
+		}
+	}
+
+	if player != nil {
+		player.stop()
+		fmt.Println(strings.Join(flattenAscii(lastAsciiSet, colored || grayscale, false), "\n"))
+	} else {
+		fmt.Printf("                     \r")
+	}
+
+	if fullPathName != "" {
+		fmt.Println("Saved " + fullPathName)
+	}
+
+	return nil
+}
+
+/*
+Draws the frames of the passed animation at the passed indices and converts them on a fixed pool of workers, one
+per CPU. Converted frames are put back in order and passed to handle one at a time. If render isn't nil, it's
+called by the workers to render each frame of the saved gif.
+
+Only a few frames past the one handle is waiting for are drawn ahead of time, so memory use doesn't grow with the
+length of the animation, even if one frame takes much longer to convert than the ones after it
+*/
+func streamAnimationFrames(anim animation, frameIndices []int, cropRect image.Rectangle, render func(asciiSet [][]imgManip.AsciiChar) (*image.Paletted, error), handle func(frame streamedFrame) error) error {
+
+	type frameJob struct {
+		index      int
+		frameImage image.Image
+		err        error
+	}
+
+	var (
+		workers = runtime.NumCPU()
+		jobs    = make(chan frameJob)
+		results = make(chan streamedFrame, workers)
+
+		// Frames that are drawn but not handled yet
+		slots = make(chan struct{}, 2*workers)
+
+		// Closed when returning, so that the goroutines below stop if an error ended the loop early
+		done = make(chan struct{})
+
+		wg sync.WaitGroup
+	)
+
+	// Workers read the package's flags, so they're waited for before those can be changed by another conversion
+	defer func() {
+		close(done)
+		for range results {
+		}
+	}()
+
+	go func() {
+		defer close(jobs)
+
+		nextFrame := anim.newCompositor()
+
+		for i, selected := 0, 0; selected < len(frameIndices); i++ {
+			frameImage, err := nextFrame(i == frameIndices[selected])
+			if err == nil && frameImage == nil {
+				continue
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			// A frame that can't be decoded is passed on as an error in its place, which ends the stream
+			select {
+			case jobs <- frameJob{selected, frameImage, err}:
+			case <-done:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+			selected++
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				frame := streamedFrame{index: job.index, err: job.err}
+
+				if frame.err == nil {
+					frame.asciiSet, frame.err = convertToAsciiSet(rotateImage(job.frameImage), cropRect)
+				}
+				if frame.err == nil && render != nil {
+					frame.rendered, frame.err = render(frame.asciiSet)
+				}
+
+				select {
+				case results <- frame:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]streamedFrame)
+	next := 0
+
+	for frame := range results {
+		pending[frame.index] = frame
+
+		for {
+			frame, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if frame.err != nil {
+				return frame.err
+			}
+
+			if err := handle(frame); err != nil {
+				return err
+			}
+
+			<-slots
+		}
+	}
+
+	return nil
+}
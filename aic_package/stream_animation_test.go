@@ -0,0 +1,171 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
+)
+
+// Returns an animation of the passed number of frames, each filled with a gray that's brighter than the last
+func grayAnimation(frameCount int) animation {
+
+	bounds := image.Rect(0, 0, 4, 2)
+	frames := make([]encodedFrame, frameCount)
+
+	for i := range frames {
+		gray := uint8(i * 255 / frameCount)
+
+		frames[i] = encodedFrame{
+			rect:     bounds,
+			disposal: gif.DisposalNone,
+			decode: func() (image.Image, error) {
+				return solidImage(bounds.Dx(), bounds.Dy(), color.NRGBA{gray, gray, gray, 255}), nil
+			},
+		}
+	}
+
+	return animation{
+		frameCount: frameCount,
+		delays:     make([]int, frameCount),
+		newCompositor: func() func(keep bool) (image.Image, error) {
+			return newEncodedFrameCompositor(bounds, frames)
+		},
+	}
+}
+
+// Sets the flags that frames are converted with to tiny dimensions, and returns a function that resets them
+func setStreamTestFlags() func() {
+	flags := DefaultFlags()
+	flags.Dimensions = []int{2, 1}
+	applyFlags(flags)
+
+	return func() { applyFlags(DefaultFlags()) }
+}
+
+func TestStreamAnimationFramesOrder(t *testing.T) {
+
+	defer setStreamTestFlags()()
+
+	anim := grayAnimation(40)
+	frameIndices := []int{0, 3, 4, 10, 11, 12, 20, 25, 30, 39}
+	cropRect := image.Rect(0, 0, 4, 2)
+
+	var want []string
+	nextFrame := anim.newCompositor()
+	for i, selected := 0, 0; selected < len(frameIndices); i++ {
+		frameImage, err := nextFrame(i == frameIndices[selected])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frameImage == nil {
+			continue
+		}
+
+		asciiSet, err := convertToAsciiSet(frameImage, cropRect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, asciiSet[0][0].Simple)
+		selected++
+	}
+
+	// Rendering takes longer for some frames than for the ones after them, which mustn't change their order
+	var renders int32
+	render := func(asciiSet [][]imgManip.AsciiChar) (*image.Paletted, error) {
+		time.Sleep(time.Duration(atomic.AddInt32(&renders, 1)%3) * time.Millisecond)
+		return image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}), nil
+	}
+
+	var got []string
+	err := streamAnimationFrames(anim, frameIndices, cropRect, render, func(frame streamedFrame) error {
+		if frame.index != len(got) {
+			return fmt.Errorf("got frame %d, want %d", frame.index, len(got))
+		}
+		if frame.rendered == nil {
+			return fmt.Errorf("frame %d wasn't rendered", frame.index)
+		}
+
+		got = append(got, frame.asciiSet[0][0].Simple)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("characters of handled frames = %q, want %q", got, want)
+	}
+}
+
+func TestStreamAnimationFramesErrors(t *testing.T) {
+
+	defer setStreamTestFlags()()
+
+	anim := grayAnimation(30)
+	frameIndices := make([]int, 30)
+	for i := range frameIndices {
+		frameIndices[i] = i
+	}
+	cropRect := image.Rect(0, 0, 4, 2)
+
+	// An error from handle stops the frames after it from being handled
+	handleErr := errors.New("handle failed")
+	handled := 0
+
+	err := streamAnimationFrames(anim, frameIndices, cropRect, nil, func(frame streamedFrame) error {
+		handled++
+		if frame.index == 7 {
+			return handleErr
+		}
+		return nil
+	})
+	if err != handleErr {
+		t.Errorf("error = %v, want %v", err, handleErr)
+	}
+	if handled != 8 {
+		t.Errorf("handled %d frames, want 8", handled)
+	}
+
+	// Frames that fail to render are returned as errors instead of being handled
+	renderErr := errors.New("render failed")
+	handled = 0
+
+	render := func(asciiSet [][]imgManip.AsciiChar) (*image.Paletted, error) {
+		return nil, renderErr
+	}
+
+	err = streamAnimationFrames(anim, frameIndices, cropRect, render, func(frame streamedFrame) error {
+		handled++
+		return nil
+	})
+	if err != renderErr {
+		t.Errorf("error = %v, want %v", err, renderErr)
+	}
+	if handled != 0 {
+		t.Errorf("handled %d frames that failed to render", handled)
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+
+	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
+)
+
+// ANSI escape sequences for controlling the terminal during playback
+const (
+	enterAlternateScreen = "\x1b[?1049h"
+	exitAlternateScreen  = "\x1b[?1049l"
+	hideCursor           = "\x1b[?25l"
+	showCursor           = "\x1b[?25h"
+	clearToLineEnd       = "\x1b[K"
+	clearToScreenEnd     = "\x1b[J"
+	resetStyle           = "\x1b[0m"
+)
+
+// Character of a frame drawn by terminalPlayer
+type terminalCell struct {
+	// SGR escape sequence setting the colors of the character, or empty for the terminal's default colors
+	style  string
+	text   string
+	column int
+	width  int
+}
+
+/*
+Draws animation frames on the alternate screen of the terminal, so that the shell's contents are left untouched.
+Only the characters that changed since the previous frame are drawn, after moving the cursor to them, which avoids
+flicker and keeps the output small when little moves. The terminal is restored when the player stops or the
+process is interrupted
+*/
+type terminalPlayer struct {
+	out      *bufio.Writer
+	mutex    sync.Mutex
+	signals  chan os.Signal
+	stopped  bool
+	previous [][]terminalCell
+
+	// State of the terminal's input before enableRawInput() was called, if it was
+	inputState *term.State
+}
+
+// Switches the terminal to its alternate screen and hides the cursor until the returned player is stopped
+func newTerminalPlayer() *terminalPlayer {
+
+	p := &terminalPlayer{
+		out:     bufio.NewWriterSize(os.Stdout, 1<<16),
+		signals: make(chan os.Signal, 1),
+	}
+
+	p.out.WriteString(enterAlternateScreen + hideCursor)
+	p.out.Flush()
+
+	signal.Notify(p.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-p.signals; ok {
+			p.stop()
+			os.Exit(0)
+		}
+	}()
+
+	return p
+}
+
+/*
+Splits the passed ascii art into the characters drawn by terminalPlayer. Colored characters are printed with their
+escape sequences around them, which are separated so that consecutive characters of the same color share them
+*/
+func getTerminalCells(asciiSet [][]imgManip.AsciiChar, colored bool) [][]terminalCell {
+
+	cells := make([][]terminalCell, len(asciiSet))
+
+	for i, line := range asciiSet {
+		cells[i] = make([]terminalCell, len(line))
+		column := 0
+
+		for j, char := range line {
+			cell := terminalCell{
+				text:   getDisplayedChar(char, colored),
+				column: column,
+				width:  imgManip.DisplayWidth(char.Simple),
+			}
+
+			if strings.HasPrefix(cell.text, "\x1b[") {
+				if end := strings.IndexByte(cell.text, 'm'); end != -1 {
+					cell.style = cell.text[:end+1]
+					cell.text = strings.TrimSuffix(cell.text[end+1:], resetStyle)
+				}
+			}
+
+			cells[i][j] = cell
+			column += cell.width
+		}
+	}
+
+	return cells
+}
+
+// Returns the column after the last character of the passed line
+func getLineEnd(line []terminalCell) int {
+	if len(line) == 0 {
+		return 0
+	}
+	last := line[len(line)-1]
+	return last.column + last.width
+}
+
+// Draws the characters of the passed frame that differ from the previous one, in a single write to the terminal
+func (p *terminalPlayer) drawFrame(frame [][]terminalCell) {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stopped {
+		return
+	}
+
+	// Cursor position and style, which are unknown until the first character is drawn
+	row, column := -1, -1
+	style := ""
+
+	moveTo := func(r, c int) {
+		if r != row || c != column {
+			fmt.Fprintf(p.out, "\x1b[%d;%dH", r+1, c+1)
+			row, column = r, c
+		}
+	}
+
+	setStyle := func(s string) {
+		if s == style {
+			return
+		}
+
+		// Styles only add to each other, so the previous one is reset first
+		if style != "" {
+			p.out.WriteString(resetStyle)
+		}
+		p.out.WriteString(s)
+		style = s
+	}
+
+	for r, line := range frame {
+		var previousLine []terminalCell
+		if r < len(p.previous) {
+			previousLine = p.previous[r]
+		}
+
+		for i, cell := range line {
+			if i < len(previousLine) && previousLine[i] == cell {
+				continue
+			}
+
+			moveTo(r, cell.column)
+			setStyle(cell.style)
+			p.out.WriteString(cell.text)
+			column += cell.width
+		}
+
+		// Leftovers of a longer previous line are cleared with the default colors
+		if end := getLineEnd(line); end < getLineEnd(previousLine) {
+			moveTo(r, end)
+			setStyle("")
+			p.out.WriteString(clearToLineEnd)
+		}
+	}
+
+	if len(frame) < len(p.previous) {
+		moveTo(len(frame), 0)
+		setStyle("")
+		p.out.WriteString(clearToScreenEnd)
+	}
+
+	setStyle("")
+	p.out.Flush()
+
+	p.previous = frame
+}
+
+// Draws the passed line of text below the last drawn frame
+func (p *terminalPlayer) drawStatus(line string) {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stopped {
+		return
+	}
+
+	fmt.Fprintf(p.out, "\x1b[%d;1H%v%v", len(p.previous)+1, line, clearToLineEnd)
+	p.out.Flush()
+}
+
+// Puts the terminal's input in raw mode, so that keys are read as soon as they're pressed and aren't echoed
+func (p *terminalPlayer) enableRawInput() error {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	p.inputState = state
+
+	return nil
+}
+
+// Leaves the alternate screen and shows the cursor again. Calling it more than once has no effect
+func (p *terminalPlayer) stop() {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+
+	signal.Stop(p.signals)
+	close(p.signals)
+
+	p.out.WriteString(showCursor + exitAlternateScreen)
+	p.out.Flush()
+
+	if p.inputState != nil {
+		term.Restore(int(os.Stdin.Fd()), p.inputState)
+	}
+}
+
+/*
+Plays the passed frames of ascii art in the terminal, playCount times or forever if it's 0. Delays are in
+hundredths of a second. Each frame is shown until a deadline counted from the start of playback, so that the time
+spent drawing frames doesn't slow the animation down. The last frame is left on the normal screen when playback ends
+*/
+func playAsciiFrames(asciiSets [][][]imgManip.AsciiChar, delays []int, playCount int, colored bool) {
+
+	if len(asciiSets) == 0 {
+		return
+	}
+
+	frames := make([][][]terminalCell, len(asciiSets))
+	for i, asciiSet := range asciiSets {
+		frames[i] = getTerminalCells(asciiSet, colored)
+	}
+
+	player := newTerminalPlayer()
+
+	deadline := time.Now()
+	for timesPlayed := 0; playCount == 0 || timesPlayed < playCount; timesPlayed++ {
+		for i, frame := range frames {
+			player.drawFrame(frame)
+			deadline = waitForNextFrame(deadline, delays[i])
+		}
+	}
+
+	player.stop()
+
+	fmt.Println(strings.Join(flattenAscii(asciiSets[len(asciiSets)-1], colored, false), "\n"))
+}
+
+/*
+Sleeps until delay hundredths of a second after the passed deadline, at which the shown frame was due, and returns
+the new deadline. A terminal that can't keep up skips ahead rather than rushing through frames to catch up
+*/
+func waitForNextFrame(deadline time.Time, delay int) time.Time {
+
+	deadline = deadline.Add(time.Duration(delay) * time.Second / 100)
+
+	if wait := time.Until(deadline); wait > 0 {
+		time.Sleep(wait)
+		return deadline
+	}
+
+	return time.Now()
+}
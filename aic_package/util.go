@@ -18,21 +18,125 @@ package aic_package
 
 import (
 	"fmt"
+	"image"
+	"image/color"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
-	"runtime"
 	"strings"
 
+	"github.com/TheZoraiz/ascii-image-converter/aic_package/winsize"
 	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
 )
 
-func saveAsciiArt(asciiSet [][]imgManip.AsciiChar, imagePath, savePath, urlImgName string, onlySave bool) error {
+// Rotates the passed image clockwise by the set angle, filling uncovered corners with the set fill color
+func rotateImage(img image.Image) image.Image {
+	fillColor := color.NRGBA{
+		uint8(rotateFillColor[0]),
+		uint8(rotateFillColor[1]),
+		uint8(rotateFillColor[2]),
+		uint8(rotateFillColor[3] * 255 / 100),
+	}
+
+	return imgManip.RotateImage(img, rotate, fillColor)
+}
+
+// Returns the character aspect ratio reported by the terminal, or the one of the font for saved files if the
+// terminal doesn't report its pixel size or the ascii art is only saved
+func getAutoCharAspect() float64 {
+	if !onlySave {
+		cellWidth, cellHeight, err := winsize.GetTerminalCellSize()
+		if err == nil {
+			return float64(cellHeight) / float64(cellWidth)
+		}
+	}
+
+	return getFontCharAspect()
+}
+
+// Returns the set matte color, or nil if it isn't set
+func getMatteColor() color.Color {
+	if matte == nil {
+		return nil
+	}
+
+	return color.NRGBA{uint8(matte[0]), uint8(matte[1]), uint8(matte[2]), 255}
+}
+
+// Returns the options for imgManip.ConvertToAsciiPixels() according to the set flags
+func getConversionOptions() imgManip.ConversionOptions {
+	return imgManip.ConversionOptions{
+		Dimensions:     dimensions,
+		Width:          width,
+		Height:         height,
+		Full:           full,
+		FlipX:          flipX,
+		FlipY:          flipY,
+		Braille:        braille,
+		Dither:         dither,
+		DitherMatrix:   ditherMatrix,
+		DitherLevels:   len(imgManip.GetCharTable(complex, customMap)),
+		WideChars:      emoji || imgManip.UsesWideChars(complex, customMap, fillText),
+		ResampleFilter: resampleFilter,
+		LinearLight:    linearLight,
+		CharAspect:     charAspect,
+		Sizing:         sizing,
+		Matte:          getMatteColor(),
+		AlphaCutoff:    alphaCutoff,
+		Adjustments:    adjustments,
+	}
+}
+
+/*
+Crops the passed image to cropRect, removes its background and pads it according to the set flags, before it's
+resized. The background is removed before padding, so that flood fills start from the image's own corners
+*/
+func prepareInputImage(img image.Image, cropRect image.Rectangle) image.Image {
+
+	img = imgManip.CropAndPad(img, cropRect, 0)
+
+	if backgroundKey != nil || floodFillBackground {
+		img = imgManip.RemoveBackground(img, backgroundKey, backgroundTolerance, floodFillBackground)
+	}
+
+	return imgManip.CropAndPad(img, img.Bounds(), padAspect)
+}
+
+// Converts the passed image into a 2D slice of ascii, braille or emoji characters according to the set flags.
+// The image is first cropped to cropRect, which should come from imgManip.GetCropRectangle()
+func convertToAsciiSet(img image.Image, cropRect image.Rectangle) ([][]imgManip.AsciiChar, error) {
+
+	img = prepareInputImage(img, cropRect)
+
+	cutoff := alphaCutoff
+
+	// Removed backgrounds and padding are fully transparent, so a cutoff is needed to turn them into blank cells
+	// unless one is set
+	if (backgroundKey != nil || floodFillBackground || padAspect > 0) && cutoff == 0 {
+		cutoff = 128
+	}
+
+	options := getConversionOptions()
+	options.AlphaCutoff = cutoff
+
+	imgSet, err := imgManip.ConvertToAsciiPixels(img, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if braille {
+		return imgManip.ConvertToBrailleChars(imgSet, negative, colored, grayscale, colorBg, fontColor, threshold, thresholdMode)
+	} else if emoji {
+		return imgManip.ConvertToEmojiChars(imgSet, negative)
+	}
+	return imgManip.ConvertToAsciiChars(imgSet, negative, colored, grayscale, complex, colorBg, customMap, fontColor, fillText)
+}
+
+func saveAsciiArt(asciiSet [][]imgManip.AsciiChar, imagePath, savePath, urlImgName, label string, onlySave bool) error {
 	// To make sure uncolored ascii art is the one saved as .txt
 	saveAscii := flattenAscii(asciiSet, false, true)
 
-	saveFileName, err := createSaveFileName(imagePath, urlImgName, "-ascii-art.txt")
+	saveFileName, err := createSaveFileName(imagePath, urlImgName, label)
 	if err != nil {
 		return err
 	}
@@ -100,13 +204,7 @@ func flattenAscii(asciiSet [][]imgManip.AsciiChar, colored, toSaveTxt bool) []st
 				continue
 			}
 
-			if colored {
-				tempAscii += char.OriginalColor
-			} else if fontColor != [3]int{255, 255, 255} {
-				tempAscii += char.SetColor
-			} else {
-				tempAscii += char.Simple
-			}
+			tempAscii += getDisplayedChar(char, colored)
 		}
 
 		ascii = append(ascii, tempAscii)
@@ -115,6 +213,34 @@ func flattenAscii(asciiSet [][]imgManip.AsciiChar, colored, toSaveTxt bool) []st
 	return ascii
 }
 
+// Returns the variant of the passed character that is printed on the terminal, according to the set colors
+func getDisplayedChar(char imgManip.AsciiChar, colored bool) string {
+	if colored {
+		return char.OriginalColor
+	} else if fontColor != [3]int{255, 255, 255} {
+		return char.SetColor
+	}
+	return char.Simple
+}
+
+// Returns the number of columns taken up by the widest line of ascii art
+func getAsciiArtColumns(asciiArt [][]imgManip.AsciiChar) int {
+	columns := 0
+
+	for _, line := range asciiArt {
+		lineColumns := 0
+		for _, char := range line {
+			lineColumns += imgManip.DisplayWidth(char.Simple)
+		}
+
+		if lineColumns > columns {
+			columns = lineColumns
+		}
+	}
+
+	return columns
+}
+
 // Returns path with the file name concatenated to it
 func getFullSavePath(imageName, saveFilePath string) (string, error) {
 	savePathLastChar := string(saveFilePath[len(saveFilePath)-1])
@@ -141,34 +267,6 @@ func isURL(urlString string) bool {
 	return false
 }
 
-// Following is for clearing screen when showing gif
-var clear map[string]func()
-
-func init() {
-	clear = make(map[string]func())
-	clear["linux"] = func() {
-		cmd := exec.Command("clear")
-		cmd.Stdout = os.Stdout
-		cmd.Run()
-	}
-	clear["windows"] = func() {
-		cmd := exec.Command("cmd", "/c", "cls")
-		cmd.Stdout = os.Stdout
-		cmd.Run()
-	}
-	clear["darwin"] = clear["linux"]
-}
-
-func clearScreen() {
-	value, ok := clear[runtime.GOOS]
-	if ok {
-		value()
-	} else {
-		fmt.Println("Error: your platform is unsupported, terminal can't be cleared")
-		os.Exit(0)
-	}
-}
-
 func isInputFromPipe() bool {
 	fileInfo, _ := os.Stdin.Stat()
 	return fileInfo.Mode()&os.ModeCharDevice == 0
@@ -0,0 +1,69 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aic_package
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestConvertToAsciiSetPadding(t *testing.T) {
+
+	// Padding a square to 3:1 leaves it in the middle third, with the padding on both sides of it
+	flags := DefaultFlags()
+	flags.Dimensions = []int{6, 2}
+	flags.PadAspect = 3
+	flags.Colored = true
+	applyFlags(flags)
+	defer applyFlags(DefaultFlags())
+
+	img := solidImage(10, 10, color.NRGBA{255, 255, 255, 255})
+
+	asciiSet, err := convertToAsciiSet(img, img.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y, row := range asciiSet {
+		if len(row) != 6 {
+			t.Fatalf("row %d has %d characters, want 6", y, len(row))
+		}
+
+		for x, char := range row {
+			padded := x < 2 || x >= 4
+			blank := char.Simple == " " && char.OriginalColor == " "
+
+			if padded && !blank {
+				t.Errorf("padded cell (%d, %d) = %q, want a blank cell", x, y, char.OriginalColor)
+			} else if !padded && blank {
+				t.Errorf("cell (%d, %d) of the image is blank", x, y)
+			}
+		}
+	}
+
+	// A matte fills the padding with its color instead
+	flags.Matte = []int{255, 255, 255}
+	applyFlags(flags)
+
+	asciiSet, err = convertToAsciiSet(img, img.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asciiSet[0][0] != asciiSet[0][2] {
+		t.Errorf("padded cell = %q, want %q like the image's white", asciiSet[0][0].OriginalColor, asciiSet[0][2].OriginalColor)
+	}
+}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package aic_package
 
+import imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
+
 type Flags struct {
 	// Set dimensions of ascii art. Accepts a slice of 2 integers
 	// e.g. []int{60,30}.
@@ -51,7 +53,9 @@ type Flags struct {
 	Colored bool
 
 	// If Flags.Colored, Flags.Grayscale or Flags.FontColor is set, use that color
-	// on each character's background in the terminal
+	// on each character's background in the terminal. For colored braille art, the
+	// background takes the color of each character's unlit dots, in saved png and gif
+	// files as well
 	CharBackgroundColor bool
 
 	// Keep grayscale colors from the original image. This uses the True color
@@ -64,12 +68,139 @@ type Flags struct {
 	// This overrides Flags.Complex
 	CustomMap string
 
+	// Write the characters of this text sequentially through the image instead of
+	// mapping brightness to characters. Blank areas of the image are skipped.
+	// This overrides Flags.Complex and Flags.CustomMap, and is ignored if Flags.Braille is set
+	FillText string
+
+	// Path to a text file whose contents are used like Flags.FillText.
+	// This overrides Flags.FillText
+	FillTextFilePath string
+
+	// Build a mosaic of emojis, picking the emoji whose average color is nearest to each
+	// part of the image. Each emoji occupies two columns, so half as many fit in a line.
+	// This overrides Flags.Complex, Flags.CustomMap and Flags.FillText, and is ignored
+	// if Flags.Braille is set. Saving images requires Flags.FontFilePath to have emoji glyphs
+	Emoji bool
+
+	// Don't rotate JPEG and TIFF images according to the orientation stored in their EXIF metadata
+	IgnoreExifOrientation bool
+
+	// Rotate the image clockwise by this many degrees before conversion, after applying its EXIF
+	// orientation. Angles that aren't multiples of 90 enlarge the image to fit the rotated one
+	Rotate float64
+
+	// RGBA color for the corners uncovered by Flags.Rotate, with alpha between 0 and 100.
+	// e.g. [4]int{0, 0, 0, 100}. Defaults to transparent
+	RotateFillColor [4]int
+
+	// Crop the image before conversion. Accepts a slice of 4 integers as x, y, width and
+	// height of the area to keep, in pixels. e.g. []int{0,0,200,100}.
+	// This overrides Flags.CropPercent
+	Crop []int
+
+	// Crop the image before conversion. Accepts a slice of 4 percentages of the image
+	// dimensions as x, y, width and height of the area to keep. e.g. []float64{10,10,80,80}
+	CropPercent []float64
+
+	// Trim borders of uniform color from the image, after cropping it. For gifs, the area
+	// trimmed from the first frame is used for every frame
+	AutoTrim bool
+
+	// Pad the image with transparent pixels on both sides until its width divided by its
+	// height equals this ratio, after cropping and trimming it. e.g. 16.0/9.0. The padding becomes
+	// blank cells, as Flags.AlphaCutoff defaults to 128 while padding. 0 disables padding
+	PadAspect float64
+
+	// Composite transparent parts of the image over this RGB color before conversion.
+	// e.g. []int{255, 255, 255}. Leave nil to keep transparency.
+	// This overrides Flags.AlphaCutoff, and removed backgrounds would be composited over it as well,
+	// so it shouldn't be combined with Flags.BackgroundKey or Flags.FloodFillBackground
+	Matte []int
+
+	// Convert pixels with an alpha value below this cutoff, between 0 and 255, into blank cells
+	// without any color. Saved png and gif files show Flags.SaveBackgroundColor in those cells.
+	// 0 disables the cutoff, unless a background is removed or the image is padded, in which case it's 128
+	AlphaCutoff int
+
+	// Remove background pixels whose RGB channels all lie within Flags.BackgroundTolerance of this color.
+	// e.g. []int{255, 255, 255}. Removed pixels become blank cells without any color, and saved png and
+	// gif files show Flags.SaveBackgroundColor in them. Flags.AlphaCutoff decides which pixels of the resized
+	// image count as removed, and defaults to 128 while a background is removed. Leave nil to disable
+	BackgroundKey []int
+
+	// Remove the background by flood filling from the corners of the image, so that matching colors inside
+	// the subject are kept. Without Flags.BackgroundKey, each corner's own color is used
+	FloodFillBackground bool
+
+	// Maximum difference of each RGB channel, between 0 and 255, for a pixel to count as background
+	BackgroundTolerance int
+
+	// Change brightness of the image before conversion. Percentage between -100 and 100
+	Brightness float64
+
+	// Change contrast of the image before conversion. Percentage between -100 and 100
+	Contrast float64
+
+	// Apply gamma correction on the image before conversion. Values less than 1 darken
+	// the image and values greater than 1 lighten it. 0 and 1 leave it unchanged
+	Gamma float64
+
+	// Change saturation of the image before conversion. Percentage between -100 and 100
+	Saturation float64
+
+	// Rotate hue of the image by this many degrees before conversion
+	HueShift float64
+
+	// Sharpen the image before conversion with this gaussian sigma. The image is sharpened
+	// after being resized, so the sigma is measured in characters (or braille dots)
+	Sharpen float64
+
+	// Blur the image before conversion with this gaussian sigma. The image is blurred
+	// after being resized, so the sigma is measured in characters (or braille dots)
+	Blur float64
+
+	// Equalize the histogram of the resized grayscale image so that the full range of characters
+	// is used. "global" equalizes the whole image, while "clahe" applies contrast limited adaptive
+	// histogram equalization on a grid of tiles. Colors are left unchanged. Leave empty to disable
+	Equalization string
+
+	// If Flags.Equalization is set and a gif is passed, compute one histogram across all frames
+	// instead of one per frame, so that the ascii art doesn't flicker
+	EqualizeAcrossFrames bool
+
+	// Height of a character cell divided by its width, used for keeping the image's aspect ratio.
+	// Saved png and gif files use the same ratio for their character cells. Defaults to 2 if 0
+	CharAspect float64
+
+	// Derive Flags.CharAspect from the terminal's pixel cell size if it's reported, or from the line
+	// height and character advance of the font used for saved png and gif files otherwise.
+	// The font is always used if Flags.OnlySave is set.
+	// This overrides Flags.CharAspect
+	AutoCharAspect bool
+
 	// Flip ascii art horizontally
 	FlipX bool
 
 	// Flip ascii art vertically
 	FlipY bool
 
+	// How the image fits in Flags.Dimensions. "stretch" fills them without keeping the aspect ratio,
+	// "contain" keeps the aspect ratio inside them and "cover" fills them, cropping the overflowing
+	// sides of the image. Defaults to "stretch" if empty.
+	// This will be ignored if Flags.Dimensions is not set
+	FitMode string
+
+	// Maximum number of characters on each line of ascii art, keeping its aspect ratio. 0 disables the limit
+	MaxWidth int
+
+	// Maximum number of lines of ascii art, keeping its aspect ratio. 0 disables the limit
+	MaxHeight int
+
+	// Shrink ascii art so that small images, like icons, aren't enlarged to fill the terminal.
+	// The image is never sampled with more pixels on either axis than it has
+	NoUpscale bool
+
 	// Use terminal width to calculate ascii art size while keeping aspect ratio.
 	// This overrides Flags.Dimensions, Flags.Width and Flags.Height
 	Full bool
@@ -92,40 +223,150 @@ type Flags struct {
 
 	// Threshold for braille art if Flags.Braille is set to true. Value provided must
 	// be between 0 and 255. Ideal value is 128.
-	// This will be ignored if Flags.Braille is not set
+	// This will be ignored if Flags.Braille is not set or Flags.ThresholdMode is not empty
 	Threshold int
 
-	// Apply FloydSteinberg dithering on an image before ascii conversion. This option
-	// is meant for braille art. Therefore, it will be ignored if Flags.Braille is false
+	// Set to "auto" for computing Otsu's threshold from the resized image's histogram, or
+	// "adaptive" for comparing each braille dot against the mean of its neighborhood.
+	// Leave empty to use Flags.Threshold for every dot.
+	// This will be ignored if Flags.Braille is not set
+	ThresholdMode string
+
+	// Apply dithering on an image before ascii conversion. For braille art, the image is
+	// dithered to black and white. For ascii art, it is dithered to the gray levels of the
+	// character set in use, which reduces banding on gradients
 	Dither bool
 
+	// Matrix used if Flags.Dither is set. Accepts "floyd-steinberg", "atkinson",
+	// "jarvis-judice-ninke", "sierra", "bayer-2x2", "bayer-4x4", "bayer-8x8" and "bayer-16x16".
+	// Defaults to "floyd-steinberg" if empty
+	DitherMatrix string
+
+	// Filter used for shrinking the image. Accepts "nearest", "box", "linear", "catmull-rom" and
+	// "lanczos", or "area" for setting each character to the mean of the pixels it covers.
+	// Defaults to "lanczos" if empty
+	ResampleFilter string
+
+	// Blend pixels in linear light instead of their gamma encoded values when shrinking the image,
+	// so that fine bright details aren't darkened
+	LinearLight bool
+
+	// Model for computing the brightness of each pixel, which decides its character. Accepts "rec601",
+	// "rec709", "lstar" for CIE L* perceived lightness, and "red", "green" or "blue" for a single channel.
+	// Defaults to "rec601" if empty
+	Luminance string
+
+	// Palette for saved gif files. "plan9" uses a fixed palette, while "median-cut" and "octree"
+	// generate one from the colors of the rendered ascii art.
+	// Defaults to "plan9" if empty
+	GifPalette string
+
+	// Generate one palette from all frames of the saved gif instead of one per frame.
+	// This will be ignored if Flags.GifPalette is "plan9"
+	GlobalGifPalette bool
+
+	// Don't dither saved gif frames to their palette, so that character edges stay clean
+	NoGifDither bool
+
+	// First and last frame of a gif to convert, both included and counted from 0.
+	// All frames are converted if nil
+	GifFrames []int
+
+	// Convert every Nth frame of a gif. Skipped frames extend the delay of the frame before them
+	GifFrameStep int
+
+	// Multiplier for the playback speed of a gif, for both the terminal and saved gifs.
+	// Defaults to 1 if 0
+	GifSpeed float64
+
+	// Number of times a gif is played, for both the terminal and saved gifs. -1 loops forever.
+	// If 0, saved gifs keep the gif's own loop count and the terminal loops forever
+	GifLoopCount int
+
+	// Play the frames of a gif in reverse order
+	GifReverse bool
+
+	// Play the frames of a gif forwards and then backwards
+	GifPingPong bool
+
+	// Convert, save and play gif frames as soon as they're decoded, instead of keeping all of them in memory.
+	// Terminal playback converts frames again on each loop. This can't be combined with Flags.GifReverse,
+	// Flags.GifPingPong, Flags.GlobalGifPalette or Flags.Interactive
+	StreamGif bool
+
+	// Control gif playback in the terminal with the keyboard. Space pauses, arrow keys step through
+	// frames, + and - change the speed, s saves the current frame and q quits
+	Interactive bool
+
+	// Frames per second of animations made by ConvertSequence(). Defaults to 10 if 0
+	FrameRate float64
+
+	// Delay between frames of animations made by ConvertSequence(), in milliseconds. Overrides
+	// Flags.FrameRate if set. Either is rounded to hundredths of a second, which gifs store delays in
+	FrameDelay int
+
 	// If Flags.SaveImagePath, Flags.SaveTxtPath or Flags.SaveGifPath are set, then don't
 	// print on terminal
 	OnlySave bool
 }
 
 var (
-	dimensions    []int
-	width         int
-	height        int
-	complex       bool
-	saveTxtPath   string
-	saveImagePath string
-	saveGifPath   string
-	grayscale     bool
-	negative      bool
-	colored       bool
-	colorBg       bool
-	customMap     string
-	flipX         bool
-	flipY         bool
-	full          bool
-	fontPath      string
-	fontColor     [3]int
-	saveBgColor   [4]int
-	braille       bool
-	threshold     int
-	dither        bool
-	onlySave      bool
-	inputIsGif    bool
+	dimensions            []int
+	width                 int
+	height                int
+	complex               bool
+	saveTxtPath           string
+	saveImagePath         string
+	saveGifPath           string
+	grayscale             bool
+	negative              bool
+	colored               bool
+	colorBg               bool
+	customMap             string
+	fillText              string
+	emoji                 bool
+	ignoreExifOrientation bool
+	rotate                float64
+	rotateFillColor       [4]int
+	crop                  []int
+	cropPercent           []float64
+	autoTrim              bool
+	padAspect             float64
+	matte                 []int
+	alphaCutoff           int
+	backgroundKey         []int
+	floodFillBackground   bool
+	backgroundTolerance   int
+	adjustments           imgManip.Adjustments
+	equalizeAcrossFrames  bool
+	flipX                 bool
+	flipY                 bool
+	full                  bool
+	fontPath              string
+	fontColor             [3]int
+	saveBgColor           [4]int
+	braille               bool
+	threshold             int
+	thresholdMode         string
+	dither                bool
+	ditherMatrix          string
+	resampleFilter        string
+	linearLight           bool
+	charAspect            float64
+	sizing                imgManip.Sizing
+	gifPalette            string
+	globalGifPalette      bool
+	noGifDither           bool
+	gifFrameRange         []int
+	gifFrameStep          int
+	gifSpeed              float64
+	gifLoopCount          int
+	gifReverse            bool
+	gifPingPong           bool
+	streamGif             bool
+	interactive           bool
+	frameRate             float64
+	frameDelay            int
+	onlySave              bool
+	inputIsGif            bool
 )
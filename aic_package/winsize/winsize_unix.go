@@ -4,6 +4,7 @@
 package winsize
 
 import (
+	"fmt"
 	"os"
 	"syscall"
 	"unsafe"
@@ -49,3 +50,26 @@ func GetTerminalSize() (int, int, error) {
 		return int(sz.cols), int(sz.rows), nil
 	}
 }
+
+// Returns the width and height of a terminal character cell in pixels, from stdout or stdin, whichever is a
+// terminal. An error is returned if neither is a terminal or the terminal doesn't report its pixel size
+func GetTerminalCellSize() (int, int, error) {
+
+	var sz struct {
+		rows    uint16
+		cols    uint16
+		xpixels uint16
+		ypixels uint16
+	}
+
+	for _, fd := range []uintptr{uintptr(syscall.Stdout), uintptr(syscall.Stdin)} {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
+			fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&sz)))
+
+		if errno == 0 && sz.cols > 0 && sz.rows > 0 && sz.xpixels > 0 && sz.ypixels > 0 {
+			return int(sz.xpixels / sz.cols), int(sz.ypixels / sz.rows), nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("terminal doesn't report its pixel size")
+}
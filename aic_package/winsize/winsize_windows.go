@@ -20,3 +20,9 @@ func GetTerminalSize() (int, int, error) {
 		return x, y, nil
 	}
 }
+
+// Returns the width and height of a terminal character cell in pixels. This isn't supported for windows yet,
+// so an error is always returned
+func GetTerminalCellSize() (int, int, error) {
+	return 0, 0, fmt.Errorf("terminal pixel size isn't currently supported on windows")
+}
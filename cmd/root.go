@@ -29,30 +29,83 @@ import (
 
 var (
 	// Flags
-	cfgFile       string
-	complex       bool
-	dimensions    []int
-	width         int
-	height        int
-	saveTxtPath   string
-	saveImagePath string
-	saveGifPath   string
-	negative      bool
-	formatsTrue   bool
-	colored       bool
-	colorBg       bool
-	grayscale     bool
-	customMap     string
-	flipX         bool
-	flipY         bool
-	full          bool
-	fontFile      string
-	fontColor     []int
-	saveBgColor   []int
-	braille       bool
-	threshold     int
-	dither        bool
-	onlySave      bool
+	cfgFile        string
+	complex        bool
+	dimensions     []int
+	width          int
+	height         int
+	saveTxtPath    string
+	saveImagePath  string
+	saveGifPath    string
+	negative       bool
+	formatsTrue    bool
+	colored        bool
+	colorBg        bool
+	grayscale      bool
+	customMap      string
+	fillText       string
+	fillTextFile   string
+	emoji          bool
+	noExifRotate   bool
+	rotate         float64
+	rotateBgColor  []int
+	crop           []int
+	cropPercentArg []string
+	cropPercent    []float64
+	autoTrim       bool
+	padAspectArg   string
+	padAspect      float64
+	matte          []int
+	alphaCutoff    int
+	bgKey          []int
+	bgFlood        bool
+	bgTolerance    int
+	brightness     float64
+	contrast       float64
+	gamma          float64
+	saturation     float64
+	hueShift       float64
+	sharpen        float64
+	blur           float64
+	equalize       string
+	equalizeGif    bool
+	charAspectArg  string
+	charAspect     float64
+	autoCharAspect bool
+	flipX          bool
+	flipY          bool
+	full           bool
+	fitMode        string
+	maxWidth       int
+	maxHeight      int
+	noUpscale      bool
+	fontFile       string
+	fontColor      []int
+	saveBgColor    []int
+	braille        bool
+	threshold      int
+	thresholdArg   string
+	thresholdMode  string
+	dither         bool
+	ditherMatrix   string
+	resample       string
+	linearLight    bool
+	luminance      string
+	gifPalette     string
+	gifGlobal      bool
+	gifNoDither    bool
+	gifFrames      []int
+	gifStep        int
+	gifSpeed       float64
+	gifLoop        int
+	gifReverse     bool
+	gifPingPong    bool
+	gifStream      bool
+	interactive    bool
+	sequence       bool
+	frameRate      float64
+	frameDelay     int
+	onlySave       bool
 
 	// Root commands
 	rootCmd = &cobra.Command{
@@ -69,28 +122,86 @@ var (
 			}
 
 			flags := aic_package.Flags{
-				Complex:             complex,
-				Dimensions:          dimensions,
-				Width:               width,
-				Height:              height,
-				SaveTxtPath:         saveTxtPath,
-				SaveImagePath:       saveImagePath,
-				SaveGifPath:         saveGifPath,
-				Negative:            negative,
-				Colored:             colored,
-				CharBackgroundColor: colorBg,
-				Grayscale:           grayscale,
-				CustomMap:           customMap,
-				FlipX:               flipX,
-				FlipY:               flipY,
-				Full:                full,
-				FontFilePath:        fontFile,
-				FontColor:           [3]int{fontColor[0], fontColor[1], fontColor[2]},
-				SaveBackgroundColor: [4]int{saveBgColor[0], saveBgColor[1], saveBgColor[2], saveBgColor[3]},
-				Braille:             braille,
-				Threshold:           threshold,
-				Dither:              dither,
-				OnlySave:            onlySave,
+				Complex:               complex,
+				Dimensions:            dimensions,
+				Width:                 width,
+				Height:                height,
+				SaveTxtPath:           saveTxtPath,
+				SaveImagePath:         saveImagePath,
+				SaveGifPath:           saveGifPath,
+				Negative:              negative,
+				Colored:               colored,
+				CharBackgroundColor:   colorBg,
+				Grayscale:             grayscale,
+				CustomMap:             customMap,
+				FillText:              fillText,
+				FillTextFilePath:      fillTextFile,
+				Emoji:                 emoji,
+				IgnoreExifOrientation: noExifRotate,
+				Rotate:                rotate,
+				RotateFillColor:       [4]int{rotateBgColor[0], rotateBgColor[1], rotateBgColor[2], rotateBgColor[3]},
+				Crop:                  crop,
+				CropPercent:           cropPercent,
+				AutoTrim:              autoTrim,
+				PadAspect:             padAspect,
+				Matte:                 matte,
+				AlphaCutoff:           alphaCutoff,
+				BackgroundKey:         bgKey,
+				FloodFillBackground:   bgFlood,
+				BackgroundTolerance:   bgTolerance,
+				Brightness:            brightness,
+				Contrast:              contrast,
+				Gamma:                 gamma,
+				Saturation:            saturation,
+				HueShift:              hueShift,
+				Sharpen:               sharpen,
+				Blur:                  blur,
+				Equalization:          equalize,
+				EqualizeAcrossFrames:  equalizeGif,
+				CharAspect:            charAspect,
+				AutoCharAspect:        autoCharAspect,
+				FlipX:                 flipX,
+				FlipY:                 flipY,
+				Full:                  full,
+				FitMode:               fitMode,
+				MaxWidth:              maxWidth,
+				MaxHeight:             maxHeight,
+				NoUpscale:             noUpscale,
+				FontFilePath:          fontFile,
+				FontColor:             [3]int{fontColor[0], fontColor[1], fontColor[2]},
+				SaveBackgroundColor:   [4]int{saveBgColor[0], saveBgColor[1], saveBgColor[2], saveBgColor[3]},
+				Braille:               braille,
+				Threshold:             threshold,
+				ThresholdMode:         thresholdMode,
+				Dither:                dither,
+				DitherMatrix:          ditherMatrix,
+				ResampleFilter:        resample,
+				LinearLight:           linearLight,
+				Luminance:             luminance,
+				GifPalette:            gifPalette,
+				GlobalGifPalette:      gifGlobal,
+				NoGifDither:           gifNoDither,
+				GifFrames:             gifFrames,
+				GifFrameStep:          gifStep,
+				GifSpeed:              gifSpeed,
+				GifLoopCount:          gifLoop,
+				GifReverse:            gifReverse,
+				GifPingPong:           gifPingPong,
+				StreamGif:             gifStream,
+				Interactive:           interactive,
+				FrameRate:             frameRate,
+				FrameDelay:            frameDelay,
+				OnlySave:              onlySave,
+			}
+
+			if sequence {
+				if err := aic_package.ConvertSequence(args, flags); err != nil {
+					fmt.Printf("Error: %v\n", err)
+				}
+				if !onlySave {
+					fmt.Println()
+				}
+				return
 			}
 
 			if args[0] == "-" {
@@ -144,24 +255,71 @@ func init() {
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ascii-image-converter.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&colored, "color", "C", false, "Display ascii art with original colors\nIf 24-bit colors aren't supported, uses 8-bit\n(Inverts with --negative flag)\n(Overrides --grayscale and --font-color flags)\n")
-	rootCmd.PersistentFlags().BoolVar(&colorBg, "color-bg", false, "If some color flag is passed, use that color\non character background instead of foreground\n(Inverts with --negative flag)\n(Only applicable for terminal display)\n")
+	rootCmd.PersistentFlags().BoolVar(&colorBg, "color-bg", false, "If some color flag is passed, use that color\non character background instead of foreground\n(Inverts with --negative flag)\n(Only applicable for terminal display,\nexcept for braille art)\n")
 	rootCmd.PersistentFlags().IntSliceVarP(&dimensions, "dimensions", "d", nil, "Set width and height for ascii art in CHARACTER length\ne.g. -d 60,30 (defaults to terminal height)\n(Overrides --width and --height flags)\n")
 	rootCmd.PersistentFlags().IntVarP(&width, "width", "W", 0, "Set width for ascii art in CHARACTER length\nHeight is kept to aspect ratio\ne.g. -W 60\n")
 	rootCmd.PersistentFlags().IntVarP(&height, "height", "H", 0, "Set height for ascii art in CHARACTER length\nWidth is kept to aspect ratio\ne.g. -H 60\n")
 	rootCmd.PersistentFlags().StringVarP(&customMap, "map", "m", "", "Give custom ascii characters to map against\nOrdered from darkest to lightest\ne.g. -m \" .-+#@\" (Quotation marks excluded from map)\n(Overrides --complex flag)\n")
-	rootCmd.PersistentFlags().BoolVarP(&braille, "braille", "b", false, "Use braille characters instead of ascii\nTerminal must support braille patterns properly\n(Overrides --complex and --map flags)\n")
-	rootCmd.PersistentFlags().IntVar(&threshold, "threshold", 0, "Threshold for braille art\nValue between 0-255 is accepted\ne.g. --threshold 170\n(Defaults to 128)\n")
-	rootCmd.PersistentFlags().BoolVar(&dither, "dither", false, "Apply dithering on image for braille\nart conversion\n(Only applicable with --braille flag)\n(Negates --threshold flag)\n")
+	rootCmd.PersistentFlags().StringVar(&fillText, "fill-text", "", "Draw the image with the characters of this\ntext in order, skipping blank areas\ne.g. --fill-text \"Hello World\"\n(Overrides --complex and --map flags)\n")
+	rootCmd.PersistentFlags().StringVar(&fillTextFile, "fill-text-file", "", "Same as --fill-text, but reads the text\nfrom the passed file path\ne.g. --fill-text-file ./main.go\n")
+	rootCmd.PersistentFlags().BoolVar(&emoji, "emoji", false, "Build a mosaic of emojis picked by their\nnearest average color\nEach emoji takes up two columns\n(Overrides --complex, --map and\n--fill-text flags)\n")
+	rootCmd.PersistentFlags().BoolVarP(&braille, "braille", "b", false, "Use braille characters instead of ascii\nTerminal must support braille patterns properly\n(Overrides --complex, --map, --fill-text\nand --emoji flags)\n")
+	rootCmd.PersistentFlags().StringVar(&thresholdArg, "threshold", "", "Threshold for braille art\nValue between 0-255 is accepted\nPass auto for Otsu's threshold or adaptive\nfor a local threshold per neighborhood\ne.g. --threshold 170\n(Defaults to 128)\n")
+	rootCmd.PersistentFlags().BoolVar(&dither, "dither", false, "Apply dithering on image before conversion\nFor ascii art, dithers to the levels of\nthe character set in use\n(Negates --threshold flag)\n")
+	rootCmd.PersistentFlags().StringVar(&ditherMatrix, "dither-matrix", "", "Matrix to use with --dither flag\nOne of floyd-steinberg, atkinson,\njarvis-judice-ninke, sierra, bayer-2x2,\nbayer-4x4, bayer-8x8 or bayer-16x16\ne.g. --dither-matrix atkinson\n(Defaults to floyd-steinberg)\n")
+	rootCmd.PersistentFlags().StringVar(&resample, "resample", "", "Filter to use for shrinking image\nOne of nearest, box, linear, catmull-rom,\nlanczos or area\ne.g. --resample area\n(Defaults to lanczos)\n")
+	rootCmd.PersistentFlags().BoolVar(&linearLight, "linear-light", false, "Blend pixels in linear light when\nshrinking image, so that fine bright\ndetails aren't darkened\n")
+	rootCmd.PersistentFlags().StringVar(&luminance, "luminance", "", "Model for brightness of each pixel\nOne of rec601, rec709, lstar, red,\ngreen or blue\ne.g. --luminance lstar\n(Defaults to rec601)\n")
 	rootCmd.PersistentFlags().BoolVarP(&grayscale, "grayscale", "g", false, "Display grayscale ascii art\n(Inverts with --negative flag)\n(Overrides --font-color flag)\n")
 	rootCmd.PersistentFlags().BoolVarP(&complex, "complex", "c", false, "Display ascii characters in a larger range\nMay result in higher quality\n")
 	rootCmd.PersistentFlags().BoolVarP(&full, "full", "f", false, "Use largest dimensions for ascii art\nthat fill the terminal width\n(Overrides --dimensions, --width and --height flags)\n")
+	rootCmd.PersistentFlags().StringVar(&fitMode, "fit", "", "How image fits in --dimensions\nPass stretch to ignore aspect ratio, contain\nto fit inside them or cover to fill them\nand crop the overflow\ne.g. --fit contain\n(Defaults to stretch)\n")
+	rootCmd.PersistentFlags().IntVar(&maxWidth, "max-width", 0, "Limit number of characters on each line\nwhile keeping aspect ratio\ne.g. --max-width 100\n")
+	rootCmd.PersistentFlags().IntVar(&maxHeight, "max-height", 0, "Limit number of lines while keeping\naspect ratio\ne.g. --max-height 40\n")
+	rootCmd.PersistentFlags().BoolVar(&noUpscale, "no-upscale", false, "Don't enlarge images that are smaller\nthan the ascii art\n")
 	rootCmd.PersistentFlags().BoolVarP(&negative, "negative", "n", false, "Display ascii art in negative colors\n")
+	rootCmd.PersistentFlags().BoolVar(&noExifRotate, "no-exif-rotate", false, "Don't rotate JPEG and TIFF images according\nto their EXIF orientation\n")
+	rootCmd.PersistentFlags().Float64Var(&rotate, "rotate", 0, "Rotate image clockwise by passed degrees\nbefore conversion\ne.g. --rotate 90\n")
+	rootCmd.PersistentFlags().IntSliceVar(&rotateBgColor, "rotate-bg", nil, "Set color of corners uncovered by --rotate\nfor angles that aren't multiples of 90\nPass an RGBA value\ne.g. --rotate-bg 255,255,255,100\n(Defaults to transparent)\n")
+	rootCmd.PersistentFlags().IntSliceVar(&crop, "crop", nil, "Crop image before conversion\nPass x, y, width and height in pixels\ne.g. --crop 0,0,200,100\n(Overrides --crop-percent flag)\n")
+	rootCmd.PersistentFlags().StringSliceVar(&cropPercentArg, "crop-percent", nil, "Crop image before conversion\nPass x, y, width and height as percentages\nof image dimensions\ne.g. --crop-percent 10,10,80,80\n")
+	rootCmd.PersistentFlags().BoolVar(&autoTrim, "trim", false, "Trim uniform borders from image\nbefore conversion\n")
+	rootCmd.PersistentFlags().StringVar(&padAspectArg, "pad-aspect", "", "Pad image to an aspect ratio before\nconversion\ne.g. --pad-aspect 16:9 or --pad-aspect 1.5\n")
+	rootCmd.PersistentFlags().IntSliceVar(&matte, "matte", nil, "Composite transparent parts of image over\npassed RGB color before conversion\ne.g. --matte 255,255,255\n(Overrides --alpha-cutoff flag)\n")
+	rootCmd.PersistentFlags().IntVar(&alphaCutoff, "alpha-cutoff", 0, "Turn pixels with alpha below passed value\n(0 to 255) into blank cells\ne.g. --alpha-cutoff 128\n(Defaults to 128 for --bg-key, --bg-flood\nand --pad-aspect flags)\n")
+	rootCmd.PersistentFlags().IntSliceVar(&bgKey, "bg-key", nil, "Remove background of passed RGB color\ne.g. --bg-key 255,255,255\n")
+	rootCmd.PersistentFlags().BoolVar(&bgFlood, "bg-flood", false, "Remove background by flood filling\nfrom image corners\n")
+	rootCmd.PersistentFlags().IntVar(&bgTolerance, "bg-tolerance", 0, "Maximum difference of each RGB channel\n(0 to 255) for --bg-key and --bg-flood\ne.g. --bg-tolerance 16\n")
+	rootCmd.PersistentFlags().Float64Var(&brightness, "brightness", 0, "Change image brightness before conversion\nPercentage between -100 and 100\ne.g. --brightness 20\n")
+	rootCmd.PersistentFlags().Float64Var(&contrast, "contrast", 0, "Change image contrast before conversion\nPercentage between -100 and 100\ne.g. --contrast 30\n")
+	rootCmd.PersistentFlags().Float64Var(&gamma, "gamma", 0, "Apply gamma correction before conversion\nLess than 1 darkens, more than 1 lightens\ne.g. --gamma 1.5\n(Defaults to 1)\n")
+	rootCmd.PersistentFlags().Float64Var(&saturation, "saturation", 0, "Change image saturation before conversion\nPercentage between -100 and 100\ne.g. --saturation 50\n")
+	rootCmd.PersistentFlags().Float64Var(&hueShift, "hue", 0, "Rotate image hue before conversion\nValue in degrees\ne.g. --hue 90\n")
+	rootCmd.PersistentFlags().Float64Var(&sharpen, "sharpen", 0, "Sharpen image before conversion\nPass sigma in characters\ne.g. --sharpen 0.8\n")
+	rootCmd.PersistentFlags().Float64Var(&blur, "blur", 0, "Blur image before conversion\nPass sigma in characters\ne.g. --blur 0.5\n")
+	rootCmd.PersistentFlags().StringVar(&equalize, "equalize", "", "Equalize image histogram so that the full\nrange of characters is used\nPass global or clahe for contrast limited\nadaptive histogram equalization\ne.g. --equalize clahe\n")
+	rootCmd.PersistentFlags().BoolVar(&equalizeGif, "equalize-across-frames", false, "Compute one histogram across all gif\nframes for --equalize to avoid flicker\n")
+	rootCmd.PersistentFlags().StringVar(&charAspectArg, "char-aspect", "", "Height of a character divided by its width\nPass auto to use the terminal's cell size\nor the font of saved files\ne.g. --char-aspect 2.2\n(Defaults to 2)\n")
 	rootCmd.PersistentFlags().BoolVarP(&flipX, "flipX", "x", false, "Flip ascii art horizontally\n")
 	rootCmd.PersistentFlags().BoolVarP(&flipY, "flipY", "y", false, "Flip ascii art vertically\n")
 	rootCmd.PersistentFlags().StringVarP(&saveImagePath, "save-img", "s", "", "Save ascii art as a .png file\nFormat: <image-name>-ascii-art.png\nImage will be saved in passed path\n(pass . for current directory)\n")
 	rootCmd.PersistentFlags().StringVar(&saveTxtPath, "save-txt", "", "Save ascii art as a .txt file\nFormat: <image-name>-ascii-art.txt\nFile will be saved in passed path\n(pass . for current directory)\n")
 	rootCmd.PersistentFlags().StringVar(&saveGifPath, "save-gif", "", "If input is a gif, save it as a .gif file\nFormat: <gif-name>-ascii-art.gif\nGif will be saved in passed path\n(pass . for current directory)\n")
 	rootCmd.PersistentFlags().IntSliceVar(&saveBgColor, "save-bg", nil, "Set background color for --save-img\nand --save-gif flags\nPass an RGBA value\ne.g. --save-bg 255,255,255,100\n(Defaults to 0,0,0,100)\n")
+	rootCmd.PersistentFlags().StringVar(&gifPalette, "gif-palette", "", "Palette for --save-gif flag\nOne of plan9, median-cut or octree\ne.g. --gif-palette median-cut\n(Defaults to plan9)\n")
+	rootCmd.PersistentFlags().BoolVar(&gifGlobal, "gif-palette-global", false, "Generate one palette from all frames\nfor --gif-palette flag\n")
+	rootCmd.PersistentFlags().BoolVar(&gifNoDither, "gif-no-dither", false, "Don't dither frames of --save-gif flag\nto their palette\n")
+	rootCmd.PersistentFlags().IntSliceVar(&gifFrames, "gif-frames", nil, "Only convert gif frames within a range\nPass first and last frame, counted from 0\ne.g. --gif-frames 10,50\n")
+	rootCmd.PersistentFlags().IntVar(&gifStep, "gif-step", 0, "Only convert every Nth gif frame\ne.g. --gif-step 2\n")
+	rootCmd.PersistentFlags().Float64Var(&gifSpeed, "gif-speed", 0, "Multiply gif playback speed, for the\nterminal as well as --save-gif flag\ne.g. --gif-speed 1.5\n(Defaults to 1)\n")
+	rootCmd.PersistentFlags().IntVar(&gifLoop, "gif-loop", 0, "Number of times to play a gif, for the\nterminal as well as --save-gif flag\nPass -1 to loop forever\ne.g. --gif-loop 3\n(Defaults to the gif's own loop count for\n--save-gif flag, and to looping forever in\nthe terminal)\n")
+	rootCmd.PersistentFlags().BoolVar(&gifReverse, "gif-reverse", false, "Play gif frames in reverse order\n")
+	rootCmd.PersistentFlags().BoolVar(&gifPingPong, "gif-ping-pong", false, "Play gif frames forwards and then\nbackwards\n")
+	rootCmd.PersistentFlags().BoolVar(&gifStream, "gif-stream", false, "Convert, save and play gif frames as\nthey're decoded to save memory\n")
+	rootCmd.PersistentFlags().BoolVar(&interactive, "interactive", false, "Control gif playback with the keyboard\nSpace pauses, arrow keys step frames,\n+/- change speed, s saves the current\nframe and q quits\n")
+	rootCmd.PersistentFlags().BoolVar(&sequence, "sequence", false, "Play and save all inputs as frames of\none animation, like a gif\nDirectories and quoted patterns are\nexpanded to the images they hold\ne.g. --sequence ./renders\n")
+	rootCmd.PersistentFlags().Float64Var(&frameRate, "fps", 0, "Frames per second for --sequence flag\nDelays are rounded to hundredths of\na second, which gifs are limited to\ne.g. --fps 24\n(Defaults to 10)\n")
+	rootCmd.PersistentFlags().IntVar(&frameDelay, "frame-delay", 0, "Delay between frames for --sequence\nflag in milliseconds, rounded to\nhundredths of a second\ne.g. --frame-delay 50\n")
 	rootCmd.PersistentFlags().StringVar(&fontFile, "font", "", "Set font for --save-img and --save-gif flags\nPass file path to font .ttf file\ne.g. --font ./RobotoMono-Regular.ttf\n(Defaults to Hack-Regular for ascii and\n DejaVuSans-Oblique for braille)\n")
 	rootCmd.PersistentFlags().IntSliceVar(&fontColor, "font-color", nil, "Set font color for terminal as well as\n--save-img and --save-gif flags\nPass an RGB value\ne.g. --font-color 0,0,0\n(Defaults to 255,255,255)\n")
 	rootCmd.PersistentFlags().BoolVar(&onlySave, "only-save", false, "Don't print ascii art on terminal\nif some saving flag is passed\n")
@@ -18,7 +18,12 @@ package cmd
 
 import (
 	"fmt"
-	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TheZoraiz/ascii-image-converter/aic_package"
+	imgManip "github.com/TheZoraiz/ascii-image-converter/image_manipulation"
 )
 
 // Check input and flag values for detecting errors or invalid inputs
@@ -30,9 +35,8 @@ func checkInputAndFlags(args []string) bool {
 	pipeCharPresent := false
 
 	for _, arg := range args {
-		extension := path.Ext(arg)
-
-		if extension == ".gif" {
+		// Animated PNG and WEBP files loop like GIFs do
+		if aic_package.IsAnimated(arg) {
 			gifPresent = true
 			gifCount++
 		} else {
@@ -44,6 +48,11 @@ func checkInputAndFlags(args []string) bool {
 		}
 	}
 
+	// Sequences are played as a single animation, however many inputs make them up
+	if sequence {
+		gifPresent, gifCount = false, 0
+	}
+
 	if gifPresent && nonGifPresent && !onlySave {
 		fmt.Printf("Error: There are other inputs along with GIFs\nDue to the potential looping nature of GIFs, non-GIFs must not be supplied alongside\n\n")
 		return true
@@ -57,11 +66,12 @@ func checkInputAndFlags(args []string) bool {
 	if formatsTrue {
 		fmt.Printf("Supported input formats:\n\n" +
 			"JPEG/JPG\n" +
-			"PNG\n" +
+			"PNG/APNG\n" +
 			"WEBP\n" +
 			"BMP\n" +
 			"TIFF/TIF\n" +
-			"GIF\n\n")
+			"GIF\n\n" +
+			"Animated PNG and WEBP files are converted like GIFs\n\n")
 		return true
 	}
 
@@ -75,11 +85,124 @@ func checkInputAndFlags(args []string) bool {
 		return true
 	}
 
-	if customMap != "" && len(customMap) < 2 {
+	if customMap != "" && utf8.RuneCountInString(customMap) < 2 {
 		fmt.Printf("Need at least 2 characters for --map flag\n\n")
 		return true
 	}
 
+	if fillText != "" && fillTextFile != "" {
+		fmt.Printf("Error: both --fill-text and --fill-text-file can't be set\n\n")
+		return true
+	}
+
+	if fillText != "" && strings.TrimSpace(fillText) == "" {
+		fmt.Printf("Error: --fill-text needs at least 1 non-whitespace character\n\n")
+		return true
+	}
+
+	if rotateBgColor == nil {
+		rotateBgColor = []int{0, 0, 0, 0}
+	} else {
+		if len(rotateBgColor) != 4 {
+			fmt.Printf("Error: --rotate-bg requires 4 values for RGBA, got %v\n\n", len(rotateBgColor))
+			return true
+		}
+
+		if rotateBgColor[0] < 0 || rotateBgColor[1] < 0 || rotateBgColor[2] < 0 || rotateBgColor[3] < 0 ||
+			rotateBgColor[0] > 255 || rotateBgColor[1] > 255 || rotateBgColor[2] > 255 || rotateBgColor[3] > 100 {
+			fmt.Printf("Error: RBG values must be between 0 and 255\n")
+			fmt.Printf("Error: Opacity value must be between 0 and 100\n\n")
+			return true
+		}
+	}
+
+	if crop != nil {
+		if len(crop) != 4 {
+			fmt.Printf("Error: --crop requires 4 values for x, y, width and height, got %v\n\n", len(crop))
+			return true
+		}
+
+		if crop[0] < 0 || crop[1] < 0 || crop[2] < 1 || crop[3] < 1 {
+			fmt.Printf("Error: invalid values for --crop\n\n")
+			return true
+		}
+	}
+
+	if cropPercentArg != nil {
+		if len(cropPercentArg) != 4 {
+			fmt.Printf("Error: --crop-percent requires 4 values for x, y, width and height, got %v\n\n", len(cropPercentArg))
+			return true
+		}
+
+		cropPercent = make([]float64, 4)
+		for i, value := range cropPercentArg {
+			var err error
+			if cropPercent[i], err = strconv.ParseFloat(value, 64); err != nil {
+				fmt.Printf("Error: invalid values for --crop-percent\n\n")
+				return true
+			}
+		}
+
+		if cropPercent[0] < 0 || cropPercent[1] < 0 || cropPercent[2] <= 0 || cropPercent[3] <= 0 ||
+			cropPercent[0]+cropPercent[2] > 100 || cropPercent[1]+cropPercent[3] > 100 {
+			fmt.Printf("Error: --crop-percent values must stay between 0 and 100\n\n")
+			return true
+		}
+	}
+
+	if padAspectArg != "" {
+		var err error
+		if padAspect, err = parseAspectRatio(padAspectArg); err != nil {
+			fmt.Printf("Error: %v\n\n", err)
+			return true
+		}
+	}
+
+	if charAspectArg == "auto" {
+		autoCharAspect = true
+	} else if charAspectArg != "" {
+		var err error
+		if charAspect, err = strconv.ParseFloat(charAspectArg, 64); err != nil || charAspect <= 0 {
+			fmt.Printf("Error: --char-aspect must be a positive number or auto\n\n")
+			return true
+		}
+	}
+
+	if brightness < -100 || brightness > 100 {
+		fmt.Printf("Error: brightness must be between -100 and 100\n\n")
+		return true
+	}
+
+	if contrast < -100 || contrast > 100 {
+		fmt.Printf("Error: contrast must be between -100 and 100\n\n")
+		return true
+	}
+
+	if saturation < -100 || saturation > 100 {
+		fmt.Printf("Error: saturation must be between -100 and 100\n\n")
+		return true
+	}
+
+	if gamma < 0 {
+		fmt.Printf("Error: gamma can't be negative\n\n")
+		return true
+	}
+
+	if sharpen < 0 || blur < 0 {
+		fmt.Printf("Error: sharpen and blur values can't be negative\n\n")
+		return true
+	}
+
+	if equalize != "" && equalize != "global" && equalize != "clahe" {
+		fmt.Printf("Error: --equalize must be either global or clahe\n\n")
+		return true
+	}
+
+	if equalizeGif && equalize == "" {
+		fmt.Printf("Error: --equalize-across-frames requires --equalize\n\n")
+		return true
+	}
+
 	if dimensions != nil {
 
 		numberOfDimensions := len(dimensions)
@@ -94,6 +217,23 @@ func checkInputAndFlags(args []string) bool {
 		}
 	}
 
+	if fitMode != "" {
+		if fitMode != "stretch" && fitMode != "contain" && fitMode != "cover" {
+			fmt.Printf("Error: --fit must be stretch, contain or cover\n\n")
+			return true
+		}
+
+		if dimensions == nil {
+			fmt.Printf("Error: --fit requires --dimensions\n\n")
+			return true
+		}
+	}
+
+	if maxWidth < 0 || maxHeight < 0 {
+		fmt.Printf("Error: --max-width and --max-height can't be negative\n\n")
+		return true
+	}
+
 	if width != 0 || height != 0 {
 
 		if width != 0 && height != 0 {
@@ -116,6 +256,45 @@ func checkInputAndFlags(args []string) bool {
 
 	}
 
+	if matte != nil {
+		if len(matte) != 3 {
+			fmt.Printf("Error: --matte requires 3 values for RGB, got %v\n\n", len(matte))
+			return true
+		}
+
+		if matte[0] < 0 || matte[1] < 0 || matte[2] < 0 || matte[0] > 255 || matte[1] > 255 || matte[2] > 255 {
+			fmt.Printf("Error: RBG values must be between 0 and 255\n\n")
+			return true
+		}
+	}
+
+	if bgKey != nil {
+		if len(bgKey) != 3 {
+			fmt.Printf("Error: --bg-key requires 3 values for RGB, got %v\n\n", len(bgKey))
+			return true
+		}
+
+		if bgKey[0] < 0 || bgKey[1] < 0 || bgKey[2] < 0 || bgKey[0] > 255 || bgKey[1] > 255 || bgKey[2] > 255 {
+			fmt.Printf("Error: RBG values must be between 0 and 255\n\n")
+			return true
+		}
+	}
+
+	if matte != nil && (bgKey != nil || bgFlood) {
+		fmt.Printf("Error: --matte can't be used with --bg-key or --bg-flood, since the removed background would be composited over it\n\n")
+		return true
+	}
+
+	if bgTolerance < 0 || bgTolerance > 255 {
+		fmt.Printf("Error: --bg-tolerance must be between 0 and 255\n\n")
+		return true
+	}
+
+	if alphaCutoff < 0 || alphaCutoff > 255 {
+		fmt.Printf("Error: --alpha-cutoff must be between 0 and 255\n\n")
+		return true
+	}
+
 	if saveBgColor == nil {
 		saveBgColor = []int{0, 0, 0, 100}
 	} else {
@@ -158,17 +337,109 @@ func checkInputAndFlags(args []string) bool {
 		}
 	}
 
-	if threshold == 0 {
-		threshold = 128
+	threshold = 128
+
+	if thresholdArg == "auto" || thresholdArg == "adaptive" {
+		thresholdMode = thresholdArg
+
+	} else if thresholdArg != "" {
+		var err error
+		if threshold, err = strconv.Atoi(thresholdArg); err != nil {
+			fmt.Printf("Error: threshold must be a number, auto or adaptive\n\n")
+			return true
+		}
+
+		// 0 has always stood for the default threshold
+		if threshold == 0 {
+			threshold = 128
+		}
+
+		if threshold < 0 || threshold > 255 {
+			fmt.Printf("Error: threshold must be between 0 and 255\n\n")
+			return true
+		}
+	}
+
+	if ditherMatrix != "" && !imgManip.IsDitherMatrix(ditherMatrix) {
+		fmt.Printf("Error: unknown dither matrix %v\n\n", ditherMatrix)
+		return true
 	}
 
-	if threshold < 0 || threshold > 255 {
-		fmt.Printf("Error: threshold must be between 0 and 255\n\n")
+	if resample != "" && !imgManip.IsResampleFilter(resample) {
+		fmt.Printf("Error: unknown resampling filter %v\n\n", resample)
 		return true
 	}
 
-	if dither && !braille {
-		fmt.Printf("Error: image dithering is only reserved for --braille flag\n\n")
+	if luminance != "" && !imgManip.IsLuminanceModel(luminance) {
+		fmt.Printf("Error: unknown luminance model %v\n\n", luminance)
+		return true
+	}
+
+	if gifPalette != "" && !imgManip.IsPaletteMethod(gifPalette) {
+		fmt.Printf("Error: unknown gif palette %v\n\n", gifPalette)
+		return true
+	}
+
+	if gifFrames != nil {
+		if len(gifFrames) != 2 {
+			fmt.Printf("Error: --gif-frames requires 2 values for first and last frame, got %v\n\n", len(gifFrames))
+			return true
+		}
+
+		if gifFrames[0] < 0 || gifFrames[1] < gifFrames[0] {
+			fmt.Printf("Error: invalid values for --gif-frames\n\n")
+			return true
+		}
+	}
+
+	if gifStep < 0 {
+		fmt.Printf("Error: --gif-step can't be negative\n\n")
+		return true
+	}
+
+	if gifSpeed < 0 {
+		fmt.Printf("Error: --gif-speed must be more than 0\n\n")
+		return true
+	}
+
+	if gifLoop < -1 {
+		fmt.Printf("Error: --gif-loop must be -1 or more\n\n")
+		return true
+	}
+
+	if gifStream && (gifReverse || gifPingPong || gifGlobal || interactive) {
+		fmt.Printf("Error: --gif-stream can't be used with --gif-reverse, --gif-ping-pong, --gif-palette-global or --interactive\n\n")
+		return true
+	}
+
+	if sequence && pipeCharPresent {
+		fmt.Printf("Error: --sequence can't be used with piped input\n\n")
+		return true
+	}
+
+	if frameRate < 0 {
+		fmt.Printf("Error: --fps can't be negative\n\n")
+		return true
+	}
+
+	if frameDelay < 0 {
+		fmt.Printf("Error: --frame-delay can't be negative\n\n")
+		return true
+	}
+
+	if (frameRate != 0 || frameDelay != 0) && !sequence {
+		fmt.Printf("Error: --fps and --frame-delay can only be used with --sequence\n\n")
+		return true
+	}
+
+	if frameRate != 0 && frameDelay != 0 {
+		fmt.Printf("Error: both --fps and --frame-delay can't be set\n\n")
+		return true
+	}
+
+	// The bundled fonts have no emoji glyphs, so saved images would only show empty boxes
+	if emoji && !braille && fontFile == "" && (saveImagePath != "" || saveGifPath != "") {
+		fmt.Printf("Error: --emoji can't be used with --save-img or --save-gif unless a font with emoji glyphs is passed through --font\n\n")
 		return true
 	}
 
@@ -179,3 +450,32 @@ func checkInputAndFlags(args []string) bool {
 
 	return false
 }
+
+// Parses an aspect ratio passed as "width:height" or as a single number
+func parseAspectRatio(ratio string) (float64, error) {
+	parts := strings.Split(ratio, ":")
+
+	var value float64
+	var err error
+
+	if len(parts) == 2 {
+		var ratioWidth, ratioHeight float64
+
+		ratioWidth, err = strconv.ParseFloat(parts[0], 64)
+		if err == nil {
+			ratioHeight, err = strconv.ParseFloat(parts[1], 64)
+		}
+		if err == nil && ratioHeight != 0 {
+			value = ratioWidth / ratioHeight
+		}
+
+	} else if len(parts) == 1 {
+		value, err = strconv.ParseFloat(parts[0], 64)
+	}
+
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid aspect ratio %v", ratio)
+	}
+
+	return value, nil
+}
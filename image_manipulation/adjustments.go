@@ -0,0 +1,453 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image_conversions
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Tonal adjustments and filters applied on the resized image before its pixels are sampled.
+// Zero values leave the image unchanged
+type Adjustments struct {
+	// Percentage between -100 and 100
+	Brightness float64
+
+	// Percentage between -100 and 100
+	Contrast float64
+
+	// Positive gamma correction value. Values less than 1 darken the image, values greater
+	// than 1 lighten it and 0 is treated as 1
+	Gamma float64
+
+	// Percentage between -100 and 100. -100 turns the image grayscale
+	Saturation float64
+
+	// Hue rotation in degrees
+	HueShift float64
+
+	// Sigma of the gaussian sharpening, in pixels of the resized image
+	Sharpen float64
+
+	// Sigma of the gaussian blur, in pixels of the resized image
+	Blur float64
+
+	// Model for computing the brightness of each pixel, which decides its character. Accepts "rec601",
+	// "rec709", "lstar", "red", "green" and "blue". Empty is treated as "rec601"
+	Luminance string
+
+	// Histogram equalization of the resized grayscale image, which decides the characters picked.
+	// "global" equalizes the histogram of the whole image, while "clahe" applies contrast limited
+	// adaptive histogram equalization on a grid of tiles. Colors are left unchanged
+	Equalization string
+
+	// Histograms to equalize with instead of the resized image's own. This allows sharing one
+	// histogram between all frames of a gif. Must be computed by ComputeEqualizationHistograms()
+	EqualizationHistograms TileHistograms
+}
+
+// Histograms of gray values over a grid of tiles, indexed by tile row and tile column
+type TileHistograms [][][256]float64
+
+// Number of tiles along each axis for clahe
+const claheTiles = 8
+
+// Contrast limit for clahe, as a multiple of the average count of a tile's histogram bin
+const claheClipLimit float64 = 3
+
+// Applies the passed adjustments on img in the order they are declared in, skipping the ones left unset
+func adjustImage(img image.Image, adjustments Adjustments) image.Image {
+
+	if adjustments.Brightness != 0 {
+		img = imaging.AdjustBrightness(img, adjustments.Brightness)
+	}
+
+	if adjustments.Contrast != 0 {
+		img = imaging.AdjustContrast(img, adjustments.Contrast)
+	}
+
+	if adjustments.Gamma != 0 && adjustments.Gamma != 1 {
+		img = imaging.AdjustGamma(img, adjustments.Gamma)
+	}
+
+	if adjustments.Saturation != 0 {
+		img = imaging.AdjustSaturation(img, adjustments.Saturation)
+	}
+
+	if math.Mod(adjustments.HueShift, 360) != 0 {
+		img = shiftHue(img, adjustments.HueShift)
+	}
+
+	if adjustments.Blur > 0 {
+		img = imaging.Blur(img, adjustments.Blur)
+	}
+
+	if adjustments.Sharpen > 0 {
+		img = imaging.Sharpen(img, adjustments.Sharpen)
+	}
+
+	return img
+}
+
+// Returns the number of tile rows and columns used by the passed equalization mode for an image of passed dimensions
+func getEqualizationTiles(equalization string, width, height int) (int, int) {
+	if equalization != "clahe" {
+		return 1, 1
+	}
+	return minInt(claheTiles, height), minInt(claheTiles, width)
+}
+
+// Computes the histograms of the grayscale values of img over the grid of tiles used by the passed equalization mode
+func computeTileHistograms(img image.Image, equalization, luminance string) TileHistograms {
+
+	b := img.Bounds()
+	tileRows, tileCols := getEqualizationTiles(equalization, b.Dx(), b.Dy())
+
+	histograms := make(TileHistograms, tileRows)
+	for i := range histograms {
+		histograms[i] = make([][256]float64, tileCols)
+	}
+
+	grayImg := grayscaleImage(img, luminance)
+
+	for y := 0; y < b.Dy(); y++ {
+		tileRow := y * tileRows / b.Dy()
+
+		for x := 0; x < b.Dx(); x++ {
+			tileCol := x * tileCols / b.Dx()
+
+			histograms[tileRow][tileCol][grayImg.Pix[y*grayImg.Stride+x*4]]++
+		}
+	}
+
+	return histograms
+}
+
+// Adds the counts of other into h. Both must have the same grid of tiles
+func (h TileHistograms) Add(other TileHistograms) {
+	for i := range h {
+		for j := range h[i] {
+			for k := 0; k < 256; k++ {
+				h[i][j][k] += other[i][j][k]
+			}
+		}
+	}
+}
+
+/*
+Returns a grayscale version of img whose histogram is equalized with the passed mode. If histograms is nil,
+they are computed from img itself. Otherwise, they must have the tile grid of the passed mode for img
+*/
+func equalizeImage(img image.Image, equalization, luminance string, histograms TileHistograms) (*image.Gray, error) {
+
+	b := img.Bounds()
+	width := b.Dx()
+	height := b.Dy()
+
+	tileRows, tileCols := getEqualizationTiles(equalization, width, height)
+
+	if histograms == nil {
+		histograms = computeTileHistograms(img, equalization, luminance)
+	} else if len(histograms) != tileRows || len(histograms[0]) != tileCols {
+		return nil, fmt.Errorf("equalization histograms don't match the dimensions of the resized image")
+	}
+
+	// Lookup table of each tile, mapping gray values to equalized ones
+	luts := make([][][256]float64, tileRows)
+	for i := range luts {
+		luts[i] = make([][256]float64, tileCols)
+		for j := range luts[i] {
+			if equalization == "clahe" {
+				luts[i][j] = getEqualizationLut(histograms[i][j], claheClipLimit)
+			} else {
+				luts[i][j] = getEqualizationLut(histograms[i][j], 0)
+			}
+		}
+	}
+
+	grayImg := grayscaleImage(img, luminance)
+	result := image.NewGray(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+
+		// Position of the pixel in the grid of tile centers, for bilinear interpolation between tiles
+		tileY := (float64(y)+0.5)*float64(tileRows)/float64(height) - 0.5
+		row0 := clampInt(int(math.Floor(tileY)), 0, tileRows-1)
+		row1 := clampInt(row0+1, 0, tileRows-1)
+		yWeight := math.Min(math.Max(tileY-float64(row0), 0), 1)
+
+		for x := 0; x < width; x++ {
+
+			tileX := (float64(x)+0.5)*float64(tileCols)/float64(width) - 0.5
+			col0 := clampInt(int(math.Floor(tileX)), 0, tileCols-1)
+			col1 := clampInt(col0+1, 0, tileCols-1)
+			xWeight := math.Min(math.Max(tileX-float64(col0), 0), 1)
+
+			value := grayImg.Pix[y*grayImg.Stride+x*4]
+
+			top := luts[row0][col0][value]*(1-xWeight) + luts[row0][col1][value]*xWeight
+			bottom := luts[row1][col0][value]*(1-xWeight) + luts[row1][col1][value]*xWeight
+
+			result.Pix[y*result.Stride+x] = clampToUint8(top*(1-yWeight) + bottom*yWeight)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Returns a lookup table that spreads the passed histogram over the full range of gray values. If clipLimit
+is greater than 0, bins are clipped at clipLimit times the average bin count and the excess is redistributed
+evenly, which limits how much contrast is amplified
+*/
+func getEqualizationLut(histogram [256]float64, clipLimit float64) [256]float64 {
+
+	var lut [256]float64
+
+	total := 0.0
+	for _, count := range histogram {
+		total += count
+	}
+
+	if total == 0 {
+		for i := range lut {
+			lut[i] = float64(i)
+		}
+		return lut
+	}
+
+	if clipLimit > 0 {
+		limit := clipLimit * total / 256
+		excess := 0.0
+
+		for i, count := range histogram {
+			if count > limit {
+				excess += count - limit
+				histogram[i] = limit
+			}
+		}
+
+		for i := range histogram {
+			histogram[i] += excess / 256
+		}
+	}
+
+	// The lowest gray value present maps to 0 so that the darkest character is always used
+	cdfMin := 0.0
+	for _, count := range histogram {
+		if count > 0 {
+			cdfMin = count
+			break
+		}
+	}
+
+	cdf := 0.0
+	for i, count := range histogram {
+		cdf += count
+
+		if total == cdfMin {
+			lut[i] = float64(i)
+		} else {
+			lut[i] = math.Max(cdf-cdfMin, 0) / (total - cdfMin) * 255
+		}
+	}
+
+	return lut
+}
+
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	} else if value > max {
+		return max
+	}
+	return value
+}
+
+// Rotates the hue of every pixel by the passed degrees while keeping its luminance
+func shiftHue(img image.Image, degrees float64) image.Image {
+
+	radians := degrees * math.Pi / 180
+	c := math.Cos(radians)
+	s := math.Sin(radians)
+
+	// Hue rotation matrix around the luminance axis of the YIQ color space
+	matrix := [3][3]float64{
+		{0.299 + 0.701*c + 0.168*s, 0.587 - 0.587*c + 0.330*s, 0.114 - 0.114*c - 0.497*s},
+		{0.299 - 0.299*c - 0.328*s, 0.587 + 0.413*c + 0.035*s, 0.114 - 0.114*c + 0.292*s},
+		{0.299 - 0.300*c + 1.250*s, 0.587 - 0.588*c - 1.050*s, 0.114 + 0.886*c - 0.203*s},
+	}
+
+	return imaging.AdjustFunc(img, func(pixel color.NRGBA) color.NRGBA {
+		r := float64(pixel.R)
+		g := float64(pixel.G)
+		b := float64(pixel.B)
+
+		return color.NRGBA{
+			R: clampToUint8(matrix[0][0]*r + matrix[0][1]*g + matrix[0][2]*b),
+			G: clampToUint8(matrix[1][0]*r + matrix[1][1]*g + matrix[1][2]*b),
+			B: clampToUint8(matrix[2][0]*r + matrix[2][1]*g + matrix[2][2]*b),
+			A: pixel.A,
+		}
+	})
+}
+
+func clampToUint8(value float64) uint8 {
+	if value < 0 {
+		return 0
+	} else if value > 255 {
+		return 255
+	}
+	return uint8(value + 0.5)
+}
+
+// Maximum difference of each RGBA channel from the corner color for a pixel to be trimmed as part of a uniform border
+const trimTolerance = 16
+
+/*
+Returns the area of img that is kept after cropping. crop takes a rectangle in pixels as x, y, width and height,
+while cropPercent takes the same values as percentages of the image dimensions. Either of them can be nil.
+If autoTrim is true, uniform borders of the same color as the top-left pixel are trimmed from the cropped area.
+
+This area is meant to be computed once and passed to CropAndPad() for every frame of a gif
+*/
+func GetCropRectangle(img image.Image, crop []int, cropPercent []float64, autoTrim bool) (image.Rectangle, error) {
+
+	b := img.Bounds()
+	rect := b
+
+	if len(crop) == 4 {
+		rect = image.Rect(crop[0], crop[1], crop[0]+crop[2], crop[1]+crop[3]).Add(b.Min)
+
+	} else if len(cropPercent) == 4 {
+		x := float64(b.Dx()) / 100
+		y := float64(b.Dy()) / 100
+
+		rect = image.Rect(
+			int(cropPercent[0]*x+0.5),
+			int(cropPercent[1]*y+0.5),
+			int((cropPercent[0]+cropPercent[2])*x+0.5),
+			int((cropPercent[1]+cropPercent[3])*y+0.5),
+		).Add(b.Min)
+	}
+
+	rect = rect.Intersect(b)
+	if rect.Empty() {
+		return rect, fmt.Errorf("crop area is outside the image")
+	}
+
+	if autoTrim {
+		rect = trimUniformBorders(img, rect)
+	}
+
+	return rect, nil
+}
+
+// Shrinks rect until none of its edges is a uniform line of the same color as its top-left pixel
+func trimUniformBorders(img image.Image, rect image.Rectangle) image.Rectangle {
+
+	reference := color.NRGBAModel.Convert(img.At(rect.Min.X, rect.Min.Y)).(color.NRGBA)
+
+	isBorderColor := func(x, y int) bool {
+		pixel := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+
+		return absDiff(pixel.R, reference.R) <= trimTolerance &&
+			absDiff(pixel.G, reference.G) <= trimTolerance &&
+			absDiff(pixel.B, reference.B) <= trimTolerance &&
+			absDiff(pixel.A, reference.A) <= trimTolerance
+	}
+
+	isUniformRow := func(y int) bool {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if !isBorderColor(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	isUniformColumn := func(x int) bool {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			if !isBorderColor(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for rect.Dy() > 1 && isUniformRow(rect.Min.Y) {
+		rect.Min.Y++
+	}
+	for rect.Dy() > 1 && isUniformRow(rect.Max.Y-1) {
+		rect.Max.Y--
+	}
+	for rect.Dx() > 1 && isUniformColumn(rect.Min.X) {
+		rect.Min.X++
+	}
+	for rect.Dx() > 1 && isUniformColumn(rect.Max.X-1) {
+		rect.Max.X--
+	}
+
+	return rect
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+/*
+Crops img to rect and, if padAspect is greater than 0, pads the result with transparent pixels on both sides
+until its width divided by its height equals padAspect. The image is left as it is if rect covers all of it
+and no padding is needed
+*/
+func CropAndPad(img image.Image, rect image.Rectangle, padAspect float64) image.Image {
+
+	if rect != img.Bounds() {
+		img = imaging.Crop(img, rect)
+	}
+
+	if padAspect <= 0 {
+		return img
+	}
+
+	imgWidth := img.Bounds().Dx()
+	imgHeight := img.Bounds().Dy()
+
+	paddedWidth := imgWidth
+	paddedHeight := imgHeight
+
+	if float64(imgWidth)/float64(imgHeight) < padAspect {
+		paddedWidth = int(float64(imgHeight)*padAspect + 0.5)
+	} else {
+		paddedHeight = int(float64(imgWidth)/padAspect + 0.5)
+	}
+
+	if paddedWidth == imgWidth && paddedHeight == imgHeight {
+		return img
+	}
+
+	padded := imaging.New(paddedWidth, paddedHeight, color.NRGBA{0, 0, 0, 0})
+
+	return imaging.Paste(padded, img, image.Pt((paddedWidth-imgWidth)/2, (paddedHeight-imgHeight)/2))
+}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package image_conversions
 
+import "strings"
+
 var (
 	// Reference taken from http://paulbourke.net/dataformats/asciiart/
 	asciiTableSimple   = " .:-=+*#%@"
@@ -40,6 +42,34 @@ type AsciiChar struct {
 	SetColor      string
 	Simple        string
 	RgbValue      [3]uint32
+
+	// Color of the character's background, if HasBackground is true. This is only
+	// set for colored braille art, where it's computed from the unlit dots
+	BgRgbValue    [3]uint32
+	HasBackground bool
+}
+
+/*
+Returns the ascii characters that brightness values are mapped against, ordered from darkest to lightest.
+If customMap isn't empty, its characters are used. Otherwise, the complex parameter decides between 70 and 10 characters
+*/
+func GetCharTable(complex bool, customMap string) []string {
+	var charSet string
+
+	if customMap != "" {
+		charSet = customMap
+	} else if complex {
+		charSet = asciiTableDetailed
+	} else {
+		charSet = asciiTableSimple
+	}
+
+	var chosenTable []string
+	for _, char := range charSet {
+		chosenTable = append(chosenTable, string(char))
+	}
+
+	return chosenTable
 }
 
 /*
@@ -48,35 +78,31 @@ to a 2D image_conversions.AsciiChar slice
 
 If complex parameter is true, values are compared to 70 levels of color density in ASCII characters.
 Otherwise, values are compared to 10 levels of color density in ASCII characters.
-*/
-func ConvertToAsciiChars(imgSet [][]AsciiPixel, negative, colored, grayscale, complex, colorBg bool, customMap string, fontColor [3]int) ([][]AsciiChar, error) {
 
-	height := len(imgSet)
-	width := len(imgSet[0])
+If fillText isn't empty, its characters are written sequentially through the image instead, wrapping
+across cells. Cells that would get the darkest character of the table are left blank and don't
+consume a character of fillText. Runs of whitespace in fillText are collapsed into single spaces.
 
-	chosenTable := map[int]string{}
+Transparent pixels are converted to blank cells without any color, which don't consume a character of fillText either.
 
-	// Turn ascii character-set string into map[int]string{} literal
-	if customMap == "" {
-		var charSet string
-
-		if complex {
-			charSet = asciiTableDetailed
-		} else {
-			charSet = asciiTableSimple
-		}
+If the characters in use include double-width characters, such as CJK characters or emojis, single-width
+characters are padded with a space so that every character occupies two columns.
+*/
+func ConvertToAsciiChars(imgSet [][]AsciiPixel, negative, colored, grayscale, complex, colorBg bool, customMap string, fontColor [3]int, fillText string) ([][]AsciiChar, error) {
 
-		for index, char := range charSet {
-			chosenTable[index] = string(char)
-		}
+	height := len(imgSet)
+	width := len(imgSet[0])
 
-	} else {
-		chosenTable = map[int]string{}
+	chosenTable := GetCharTable(complex, customMap)
 
-		for index, char := range customMap {
-			chosenTable[index] = string(char)
-		}
+	var fillChars []rune
+	if words := strings.Fields(fillText); len(words) > 0 {
+		// Trailing space separates the end of the text from its start when it wraps around
+		fillChars = []rune(strings.Join(words, " ") + " ")
 	}
+	fillIndex := 0
+
+	wideChars := UsesWideChars(complex, customMap, fillText)
 
 	var result [][]AsciiChar
 
@@ -85,6 +111,16 @@ func ConvertToAsciiChars(imgSet [][]AsciiPixel, negative, colored, grayscale, co
 		var tempSlice []AsciiChar
 
 		for j := 0; j < width; j++ {
+
+			if imgSet[i][j].transparent {
+				if wideChars {
+					tempSlice = append(tempSlice, getBlankChar(2))
+				} else {
+					tempSlice = append(tempSlice, getBlankChar(1))
+				}
+				continue
+			}
+
 			value := float64(imgSet[i][j].charDepth)
 
 			// Gets appropriate string index from chosenTable by percentage comparisons with its length
@@ -125,6 +161,20 @@ func ConvertToAsciiChars(imgSet [][]AsciiPixel, negative, colored, grayscale, co
 			var char AsciiChar
 
 			asciiChar := chosenTable[tempInt]
+
+			if len(fillChars) > 0 {
+				if tempInt == 0 {
+					asciiChar = " "
+				} else {
+					asciiChar = string(fillChars[fillIndex%len(fillChars)])
+					fillIndex++
+				}
+			}
+
+			if wideChars && DisplayWidth(asciiChar) == 1 {
+				asciiChar += " "
+			}
+
 			char.Simple = asciiChar
 
 			var err error
@@ -171,15 +221,32 @@ func ConvertToAsciiChars(imgSet [][]AsciiPixel, negative, colored, grayscale, co
 Converts the 2D image_conversions.AsciiPixel slice of image data (each instance representing each compressed pixel of original image)
 to a 2D image_conversions.AsciiChar slice
 
-Unlike ConvertToAsciiChars(), this function calculates braille characters instead of ascii
+Unlike ConvertToAsciiChars(), this function calculates braille characters instead of ascii.
+The thresholdMode parameter decides how each dot is compared. "auto" computes Otsu's threshold from the
+image's histogram, "adaptive" compares each dot against the mean of its neighborhood and any other value
+uses the passed threshold for every dot.
+
+Transparent pixels are treated as unlit dots and are left out of the cell colors. Cells made entirely of
+transparent pixels are converted to blank cells without any color
 */
-func ConvertToBrailleChars(imgSet [][]AsciiPixel, negative, colored, grayscale, colorBg bool, fontColor [3]int, threshold int) ([][]AsciiChar, error) {
+func ConvertToBrailleChars(imgSet [][]AsciiPixel, negative, colored, grayscale, colorBg bool, fontColor [3]int, threshold int, thresholdMode string) ([][]AsciiChar, error) {
 
 	BrailleThreshold = uint32(threshold)
 
 	height := len(imgSet)
 	width := len(imgSet[0])
 
+	var thresholds [][]uint32
+
+	switch thresholdMode {
+	case "auto":
+		thresholds = getUniformThresholds(imgSet, getOtsuThreshold(imgSet))
+	case "adaptive":
+		thresholds = getAdaptiveThresholds(imgSet)
+	default:
+		thresholds = getUniformThresholds(imgSet, uint32(threshold))
+	}
+
 	var result [][]AsciiChar
 
 	for i := 0; i < height; i += 4 {
@@ -188,40 +255,45 @@ func ConvertToBrailleChars(imgSet [][]AsciiPixel, negative, colored, grayscale,
 
 		for j := 0; j < width; j += 2 {
 
-			brailleChar := getBrailleChar(i, j, negative, imgSet)
+			if isBrailleCellTransparent(i, j, imgSet) {
+				tempSlice = append(tempSlice, getBlankChar(1))
+				continue
+			}
 
-			var r, g, b int
+			brailleChar := getBrailleChar(i, j, negative, imgSet, thresholds)
 
-			if colored {
-				r = int(imgSet[i][j].rgbValue[0])
-				g = int(imgSet[i][j].rgbValue[1])
-				b = int(imgSet[i][j].rgbValue[2])
-			} else {
-				r = int(imgSet[i][j].grayscaleValue[0])
-				g = int(imgSet[i][j].grayscaleValue[1])
-				b = int(imgSet[i][j].grayscaleValue[2])
-			}
+			// The character takes the average color of its lit dots, while its
+			// background takes the average color of its unlit dots
+			litColor, unlitColor := getBrailleCellColors(i, j, negative, colored, imgSet, thresholds)
 
 			if negative {
-				// Select character from opposite side of table as well as turn pixels negative
-				r = 255 - r
-				g = 255 - g
-				b = 255 - b
-
-				if colored {
-					imgSet[i][j].rgbValue = [3]uint32{uint32(r), uint32(g), uint32(b)}
-				} else {
-					imgSet[i][j].grayscaleValue = [3]uint32{uint32(r), uint32(g), uint32(b)}
+				// Turn pixels negative
+				for k := 0; k < 3; k++ {
+					litColor[k] = 255 - litColor[k]
+					unlitColor[k] = 255 - unlitColor[k]
 				}
 			}
 
+			r := int(litColor[0])
+			g := int(litColor[1])
+			b := int(litColor[2])
+
 			var char AsciiChar
 
 			char.Simple = brailleChar
 
 			var err error
 			if colorBg {
-				char.OriginalColor, err = getColoredCharForTerm(uint8(r), uint8(g), uint8(b), brailleChar, true)
+				char.OriginalColor, err = getColoredCharWithBgForTerm(
+					[3]uint8{uint8(r), uint8(g), uint8(b)},
+					[3]uint8{uint8(unlitColor[0]), uint8(unlitColor[1]), uint8(unlitColor[2])},
+					brailleChar,
+				)
+
+				if colored || grayscale {
+					char.BgRgbValue = unlitColor
+					char.HasBackground = true
+				}
 			} else {
 				char.OriginalColor, err = getColoredCharForTerm(uint8(r), uint8(g), uint8(b), brailleChar, false)
 			}
@@ -245,11 +317,7 @@ func ConvertToBrailleChars(imgSet [][]AsciiPixel, negative, colored, grayscale,
 				}
 			}
 
-			if colored {
-				char.RgbValue = imgSet[i][j].rgbValue
-			} else {
-				char.RgbValue = imgSet[i][j].grayscaleValue
-			}
+			char.RgbValue = litColor
 
 			tempSlice = append(tempSlice, char)
 		}
@@ -261,23 +329,113 @@ func ConvertToBrailleChars(imgSet [][]AsciiPixel, negative, colored, grayscale,
 }
 
 // Iterate through the BrailleStruct table to see which dots need to be highlighted
-func getBrailleChar(x, y int, negative bool, imgSet [][]AsciiPixel) string {
+func getBrailleChar(x, y int, negative bool, imgSet [][]AsciiPixel, thresholds [][]uint32) string {
 
 	brailleChar := 0x2800
 
 	for i := 0; i < 4; i++ {
 		for j := 0; j < 2; j++ {
-			if negative {
-				if imgSet[x+i][y+j].charDepth <= BrailleThreshold {
-					brailleChar += BrailleStruct[i][j]
+			if isBrailleDotLit(x+i, y+j, negative, imgSet, thresholds) {
+				brailleChar += BrailleStruct[i][j]
+			}
+		}
+	}
+
+	return string(rune(brailleChar))
+}
+
+func isBrailleDotLit(x, y int, negative bool, imgSet [][]AsciiPixel, thresholds [][]uint32) bool {
+	if imgSet[x][y].transparent {
+		return false
+	}
+	if negative {
+		return imgSet[x][y].charDepth <= thresholds[x][y]
+	}
+	return imgSet[x][y].charDepth >= thresholds[x][y]
+}
+
+/*
+Returns the average colors of the lit and unlit dots of the braille character at the passed position.
+If every dot is lit or every dot is unlit, the missing average falls back to the average of all dots.
+Transparent dots are left out
+*/
+func getBrailleCellColors(x, y int, negative, colored bool, imgSet [][]AsciiPixel, thresholds [][]uint32) ([3]uint32, [3]uint32) {
+
+	var litSum, unlitSum [3]uint32
+	var litCount, unlitCount uint32
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 2; j++ {
+
+			if imgSet[x+i][y+j].transparent {
+				continue
+			}
+
+			var value [3]uint32
+			if colored {
+				value = imgSet[x+i][y+j].rgbValue
+			} else {
+				value = imgSet[x+i][y+j].grayscaleValue
+			}
+
+			if isBrailleDotLit(x+i, y+j, negative, imgSet, thresholds) {
+				for k := 0; k < 3; k++ {
+					litSum[k] += value[k]
 				}
+				litCount++
 			} else {
-				if imgSet[x+i][y+j].charDepth >= BrailleThreshold {
-					brailleChar += BrailleStruct[i][j]
+				for k := 0; k < 3; k++ {
+					unlitSum[k] += value[k]
 				}
+				unlitCount++
+			}
+		}
+	}
+
+	var lit, unlit [3]uint32
+
+	for k := 0; k < 3; k++ {
+		var total uint32
+		if litCount+unlitCount > 0 {
+			total = (litSum[k] + unlitSum[k]) / (litCount + unlitCount)
+		}
+
+		if litCount == 0 {
+			lit[k] = total
+		} else {
+			lit[k] = litSum[k] / litCount
+		}
+
+		if unlitCount == 0 {
+			unlit[k] = total
+		} else {
+			unlit[k] = unlitSum[k] / unlitCount
+		}
+	}
+
+	return lit, unlit
+}
+
+// Returns true if all dots of the braille character at the passed position are transparent
+func isBrailleCellTransparent(x, y int, imgSet [][]AsciiPixel) bool {
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 2; j++ {
+			if !imgSet[x+i][y+j].transparent {
+				return false
 			}
 		}
 	}
 
-	return string(brailleChar)
+	return true
+}
+
+// Returns a cell of passed number of columns with no character and no color, for transparent parts of the image
+func getBlankChar(columns int) AsciiChar {
+	blank := strings.Repeat(" ", columns)
+
+	return AsciiChar{
+		OriginalColor: blank,
+		SetColor:      blank,
+		Simple:        blank,
+	}
 }
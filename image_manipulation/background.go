@@ -0,0 +1,117 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image_conversions
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+/*
+Makes the background of img transparent, so that it's converted to blank cells.
+
+If keyColor isn't nil, pixels whose RGB channels all lie within tolerance of it are removed. If floodFill is true,
+only the regions connected to the corners of the image are removed, so that matching colors inside the subject
+are kept. Without keyColor, each corner's region is grown from that corner's own color
+*/
+func RemoveBackground(img image.Image, keyColor []int, tolerance int, floodFill bool) image.Image {
+
+	result := imaging.Clone(img)
+	b := result.Bounds()
+	width := b.Dx()
+	height := b.Dy()
+
+	if width == 0 || height == 0 {
+		return result
+	}
+
+	matches := func(x, y int, reference [3]uint8) bool {
+		i := result.PixOffset(x, y)
+
+		return int(absDiff(result.Pix[i], reference[0])) <= tolerance &&
+			int(absDiff(result.Pix[i+1], reference[1])) <= tolerance &&
+			int(absDiff(result.Pix[i+2], reference[2])) <= tolerance
+	}
+
+	remove := func(x, y int) {
+		result.Pix[result.PixOffset(x, y)+3] = 0
+	}
+
+	if !floodFill {
+		if keyColor == nil {
+			return result
+		}
+
+		reference := [3]uint8{uint8(keyColor[0]), uint8(keyColor[1]), uint8(keyColor[2])}
+
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if matches(x, y, reference) {
+					remove(x, y)
+				}
+			}
+		}
+
+		return result
+	}
+
+	visited := make([]bool, width*height)
+	corners := []image.Point{{0, 0}, {width - 1, 0}, {0, height - 1}, {width - 1, height - 1}}
+
+	for _, corner := range corners {
+		if visited[corner.Y*width+corner.X] {
+			continue
+		}
+
+		var reference [3]uint8
+		if keyColor != nil {
+			reference = [3]uint8{uint8(keyColor[0]), uint8(keyColor[1]), uint8(keyColor[2])}
+		} else {
+			i := result.PixOffset(corner.X, corner.Y)
+			reference = [3]uint8{result.Pix[i], result.Pix[i+1], result.Pix[i+2]}
+		}
+
+		if !matches(corner.X, corner.Y, reference) {
+			continue
+		}
+
+		// Iterative flood fill, since recursion could overflow the stack on large images
+		stack := []image.Point{corner}
+		visited[corner.Y*width+corner.X] = true
+
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			remove(p.X, p.Y)
+
+			for _, n := range []image.Point{{p.X - 1, p.Y}, {p.X + 1, p.Y}, {p.X, p.Y - 1}, {p.X, p.Y + 1}} {
+				if n.X < 0 || n.Y < 0 || n.X >= width || n.Y >= height || visited[n.Y*width+n.X] {
+					continue
+				}
+
+				if matches(n.X, n.Y, reference) {
+					visited[n.Y*width+n.X] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+	}
+
+	return result
+}
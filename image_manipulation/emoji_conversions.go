@@ -0,0 +1,152 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image_conversions
+
+import (
+	"golang.org/x/text/width"
+)
+
+type emojiColor struct {
+	emoji    string
+	rgbValue [3]uint32
+}
+
+// Emojis used for mosaics, along with the average color of their common designs
+var emojiTable = []emojiColor{
+	{"⬛", [3]uint32{49, 55, 61}},
+	{"⬜", [3]uint32{230, 231, 232}},
+	{"🟥", [3]uint32{221, 46, 68}},
+	{"🟧", [3]uint32{244, 144, 12}},
+	{"🟨", [3]uint32{253, 203, 88}},
+	{"🟩", [3]uint32{120, 177, 89}},
+	{"🟦", [3]uint32{85, 172, 238}},
+	{"🟪", [3]uint32{170, 142, 214}},
+	{"🟫", [3]uint32{193, 105, 79}},
+	{"🌑", [3]uint32{102, 117, 127}},
+	{"🌲", [3]uint32{62, 114, 29}},
+	{"🌊", [3]uint32{59, 136, 195}},
+	{"🍊", [3]uint32{244, 144, 12}},
+	{"🍋", [3]uint32{255, 204, 77}},
+	{"🍇", [3]uint32{116, 78, 170}},
+	{"🌸", [3]uint32{247, 152, 166}},
+	{"🍫", [3]uint32{140, 80, 50}},
+	{"🐚", [3]uint32{255, 216, 182}},
+}
+
+// Returns the number of terminal columns the passed string occupies.
+// East Asian wide and fullwidth characters, which include most emojis, take up two columns
+func DisplayWidth(s string) int {
+	columns := 0
+
+	for _, char := range s {
+		switch width.LookupRune(char).Kind() {
+		case width.EastAsianWide, width.EastAsianFullwidth:
+			columns += 2
+		default:
+			columns++
+		}
+	}
+
+	return columns
+}
+
+// Returns true if the characters that ConvertToAsciiChars() would use include double-width characters.
+// These are the characters of fillText if it isn't empty, or the chosen character table otherwise
+func UsesWideChars(complex bool, customMap, fillText string) bool {
+	chars := GetCharTable(complex, customMap)
+	if fillText != "" {
+		chars = []string{fillText}
+	}
+
+	for _, char := range chars {
+		if DisplayWidth(char) > len([]rune(char)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Converts the 2D image_conversions.AsciiPixel slice of image data (each instance representing each compressed pixel of original image)
+to a 2D image_conversions.AsciiChar slice
+
+Unlike ConvertToAsciiChars(), this function picks the emoji whose average color is nearest to each pixel's color.
+Since emojis carry their own colors, no color escape codes are added to them. Transparent pixels are converted
+to blank cells of two columns
+*/
+func ConvertToEmojiChars(imgSet [][]AsciiPixel, negative bool) ([][]AsciiChar, error) {
+
+	height := len(imgSet)
+	width := len(imgSet[0])
+
+	var result [][]AsciiChar
+
+	for i := 0; i < height; i++ {
+
+		var tempSlice []AsciiChar
+
+		for j := 0; j < width; j++ {
+
+			if imgSet[i][j].transparent {
+				tempSlice = append(tempSlice, getBlankChar(2))
+				continue
+			}
+
+			value := imgSet[i][j].rgbValue
+
+			if negative {
+				for k := 0; k < 3; k++ {
+					value[k] = 255 - value[k]
+				}
+			}
+
+			nearest := emojiTable[0]
+			nearestDistance := colorDistance(value, nearest.rgbValue)
+
+			for _, candidate := range emojiTable[1:] {
+				if distance := colorDistance(value, candidate.rgbValue); distance < nearestDistance {
+					nearest = candidate
+					nearestDistance = distance
+				}
+			}
+
+			tempSlice = append(tempSlice, AsciiChar{
+				OriginalColor: nearest.emoji,
+				SetColor:      nearest.emoji,
+				Simple:        nearest.emoji,
+				RgbValue:      nearest.rgbValue,
+			})
+		}
+
+		result = append(result, tempSlice)
+	}
+
+	return result, nil
+}
+
+// Squared euclidean distance between two RGB colors
+func colorDistance(c1, c2 [3]uint32) int {
+	distance := 0
+
+	for k := 0; k < 3; k++ {
+		diff := int(c1[k]) - int(c2[k])
+		distance += diff * diff
+	}
+
+	return distance
+}
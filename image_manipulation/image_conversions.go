@@ -21,33 +21,132 @@ import (
 	"image/color"
 )
 
+// Options for fitting the image into the ascii art dimensions. Zero values leave the size unchanged
+type Sizing struct {
+	// How the image fits in the passed dimensions. "stretch" fills them without keeping the aspect ratio,
+	// "contain" keeps the aspect ratio inside them and "cover" fills them, cropping the overflowing sides.
+	// Empty is treated as "stretch"
+	FitMode string
+
+	// Maximum number of characters on each line
+	MaxWidth int
+
+	// Maximum number of lines
+	MaxHeight int
+
+	// Never sample the image with more pixels on either axis than it has
+	NoUpscale bool
+}
+
+// Options for turning an image into ascii pixels with ConvertToAsciiPixels()
+type ConversionOptions struct {
+	// Width and height of the ascii art, in characters. Left empty to fit the terminal
+	Dimensions []int
+
+	// Width or height of the ascii art in characters, with the other one calculated from the aspect ratio.
+	// Only one of them can be set, and neither if Dimensions is set
+	Width  int
+	Height int
+
+	// Fit the terminal's width instead of its height
+	Full bool
+
+	// Mirror the ascii art horizontally or vertically
+	FlipX bool
+	FlipY bool
+
+	// Sample 2x4 pixels for each character, one for each braille dot
+	Braille bool
+
+	// Dither the image to the levels of the character set, or to black and white for braille.
+	// DitherMatrix picks the matrix, as accepted by IsDitherMatrix(). Empty defaults to Floyd-Steinberg
+	Dither       bool
+	DitherMatrix string
+
+	// Number of characters in the character table in use, which is how many gray levels ascii art is dithered to
+	DitherLevels int
+
+	// Each character occupies two columns, so half as many pixels are kept on each line
+	WideChars bool
+
+	// Filter used for shrinking the image. "area" averages the block of pixels each resized pixel covers,
+	// and an empty or unknown name defaults to lanczos
+	ResampleFilter string
+
+	// Blend pixels in linear light instead of their gamma encoded values while resizing
+	LinearLight bool
+
+	// Height of a character divided by its width, used for keeping the image's aspect ratio. 0 defaults to 2
+	CharAspect float64
+
+	// How the image fits in the dimensions, and limits on the size of the result
+	Sizing Sizing
+
+	// If not nil, the resized image is composited over this color so that no transparency remains
+	Matte color.Color
+
+	// Pixels with an alpha value below this are marked as transparent. 0 disables the cutoff
+	AlphaCutoff int
+
+	// Applied on the resized image before its pixels are sampled
+	Adjustments Adjustments
+}
+
 type AsciiPixel struct {
 	charDepth      uint32
 	grayscaleValue [3]uint32
 	rgbValue       [3]uint32
+
+	// Set for pixels more transparent than the alpha cutoff, which are converted to blank cells
+	transparent bool
 }
 
 /*
-This function shrinks the passed image according to specified or default dimensions.
+This function shrinks the passed image according to the dimensions of the passed options.
 Stores each pixel's grayscale and RGB values in an AsciiPixel instance to simplify
 getting numeric data for ASCII character comparison.
 
 The returned 2D AsciiPixel slice contains each corresponding pixel's values
 */
-func ConvertToAsciiPixels(img image.Image, dimensions []int, width, height int, flipX, flipY, full, isBraille, dither bool) ([][]AsciiPixel, error) {
+func ConvertToAsciiPixels(img image.Image, options ConversionOptions) ([][]AsciiPixel, error) {
 
-	smallImg, err := resizeImage(img, full, isBraille, dimensions, width, height)
+	adjustments := options.Adjustments
+
+	smallImg, err := prepareImage(img, options)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Equalized grayscale image, from which charDepth is taken instead if histogram equalization is applied
+	var equalizedImage *image.Gray
+	ditherSource := smallImg
+
+	if adjustments.Equalization != "" {
+		equalizedImage, err = equalizeImage(smallImg, adjustments.Equalization, adjustments.Luminance, adjustments.EqualizationHistograms)
+		if err != nil {
+			return nil, err
+		}
+		ditherSource = equalizedImage
+	}
+
 	// We mainatin a dithered image literal along with original image
 	// The colors are kept from original image
 	var ditheredImage image.Image
 
-	if isBraille && dither {
-		ditheredImage = ditherImage(smallImg)
+	if options.Dither {
+		if options.Braille {
+			ditheredImage, err = ditherImage(ditherSource, options.DitherMatrix, []color.Color{color.Black, color.White})
+		} else {
+			// Ascii art is dithered in grayscale to the levels of the character table in use
+			if equalizedImage == nil {
+				ditherSource = grayscaleImage(ditherSource, adjustments.Luminance)
+			}
+			ditheredImage, err = ditherImage(ditherSource, options.DitherMatrix, getGrayLevelPalette(options.DitherLevels))
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var imgSet [][]AsciiPixel
@@ -61,37 +160,47 @@ func ConvertToAsciiPixels(img image.Image, dimensions []int, width, height int,
 		for x := b.Min.X; x < b.Max.X; x++ {
 
 			oldPixel := smallImg.At(x, y)
-			grayPixel := color.GrayModel.Convert(oldPixel)
 
-			r1, g1, b1, _ := grayPixel.RGBA()
-			charDepth := r1 / 257 // Only Red is needed from RGB for charDepth in AsciiPixel since they have the same value for grayscale images
-			r1 = uint32(r1 / 257)
-			g1 = uint32(g1 / 257)
-			b1 = uint32(b1 / 257)
+			// Get co1ored RGB values of original pixel for rgbValue in AsciiPixel
+			r2, g2, b2, a2 := oldPixel.RGBA()
+			r2 = uint32(r2 / 257)
+			g2 = uint32(g2 / 257)
+			b2 = uint32(b2 / 257)
+
+			// Brightness of the pixel according to the chosen luminance model decides both its character and its grayscale color
+			var charDepth uint32
+			if adjustments.Luminance == "" || adjustments.Luminance == "rec601" {
+				// color.GrayModel weighs the 16 bit channels with the Rec.601 coefficients, which is more precise
+				charDepth = uint32(color.GrayModel.Convert(oldPixel).(color.Gray).Y)
+			} else {
+				charDepth = uint32(getLuminance(uint8(r2), uint8(g2), uint8(b2), adjustments.Luminance))
+			}
+			r1 := charDepth
+			g1 := charDepth
+			b1 := charDepth
+
+			if equalizedImage != nil {
+				charDepth = uint32(equalizedImage.GrayAt(x-b.Min.X, y-b.Min.Y).Y)
+			}
 
-			if isBraille && dither {
+			if options.Dither {
 
 				// Change charDepth if image dithering is applied
 				// 		Note that neither grayscale nor original color values are changed.
 				// 		Only charDepth is kept from dithered image. This is because a
 				// 		dithered image loses its colors so it's only used to check braille
-				// 		dots' visibility
+				// 		dots' visibility or to pick ascii characters
 
 				ditheredGrayPixel := color.GrayModel.Convert(ditheredImage.At(x, y))
 				charDepth, _, _, _ = ditheredGrayPixel.RGBA()
 				charDepth = charDepth / 257
 			}
 
-			// Get co1ored RGB values of original pixel for rgbValue in AsciiPixel
-			r2, g2, b2, _ := oldPixel.RGBA()
-			r2 = uint32(r2 / 257)
-			g2 = uint32(g2 / 257)
-			b2 = uint32(b2 / 257)
-
 			temp = append(temp, AsciiPixel{
 				charDepth:      charDepth,
 				grayscaleValue: [3]uint32{r1, g1, b1},
 				rgbValue:       [3]uint32{r2, g2, b2},
+				transparent:    int(a2/257) < options.AlphaCutoff,
 			})
 
 		}
@@ -99,9 +208,39 @@ func ConvertToAsciiPixels(img image.Image, dimensions []int, width, height int,
 	}
 
 	// This rarely affects performance since the ascii art 2D slice size isn't that large
-	if flipX || flipY {
-		imgSet = reverse(imgSet, flipX, flipY)
+	if options.FlipX || options.FlipY {
+		imgSet = reverse(imgSet, options.FlipX, options.FlipY)
 	}
 
 	return imgSet, nil
 }
+
+// Resizes the passed image, composites it over the matte and applies the adjustments of the passed options
+func prepareImage(img image.Image, options ConversionOptions) (image.Image, error) {
+
+	smallImg, err := resizeImage(img, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Matte != nil {
+		smallImg = compositeOverMatte(smallImg, options.Matte)
+	}
+
+	return adjustImage(smallImg, options.Adjustments), nil
+}
+
+/*
+Computes the histograms that ConvertToAsciiPixels() equalizes the passed image with, from the same resized image
+it samples. Histograms of several images that are resized to the same dimensions, such as gif frames, can be
+combined with TileHistograms.Add() and then passed through Adjustments.EqualizationHistograms
+*/
+func ComputeEqualizationHistograms(img image.Image, options ConversionOptions) (TileHistograms, error) {
+
+	smallImg, err := prepareImage(img, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeTileHistograms(smallImg, options.Adjustments.Equalization, options.Adjustments.Luminance), nil
+}
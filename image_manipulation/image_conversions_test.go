@@ -0,0 +1,103 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image_conversions
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// Returns an image with a horizontal gradient over its left half and a vertical one over its right half
+func gradientImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := uint8(x * 255 / width)
+			if x >= width/2 {
+				value = uint8(y * 255 / height)
+			}
+			img.SetNRGBA(x, y, color.NRGBA{value, value / 2, 255 - value, 255})
+		}
+	}
+	return img
+}
+
+func TestComputeEqualizationHistograms(t *testing.T) {
+
+	img := gradientImage(200, 120)
+
+	// Fewer lines than clahe has tiles, with adjustments that change the histogram at the resized scale
+	options := ConversionOptions{
+		Dimensions: []int{30, 5},
+		Matte:      color.White,
+		Adjustments: Adjustments{
+			Contrast:     20,
+			Blur:         1,
+			Equalization: "clahe",
+		},
+	}
+
+	want, err := ConvertToAsciiPixels(img, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	histograms, err := ComputeEqualizationHistograms(img, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(histograms) != 5 || len(histograms[0]) != 8 {
+		t.Fatalf("tile grid = %dx%d, want 5x8", len(histograms), len(histograms[0]))
+	}
+
+	// Equalizing with an image's own histograms is the same as equalizing without shared ones
+	options.Adjustments.EqualizationHistograms = histograms
+
+	got, err := ConvertToAsciiPixels(img, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("ascii pixels equalized with the image's own histograms differ from the ones equalized without them")
+	}
+
+	// Histograms of another tile grid are rejected rather than ignored
+	options.Dimensions = []int{30, 20}
+	if _, err := ConvertToAsciiPixels(img, options); err == nil {
+		t.Error("ConvertToAsciiPixels() with histograms of another tile grid didn't fail")
+	}
+}
+
+func TestResizeImageCoverNoUpscale(t *testing.T) {
+
+	// Covering a 20x10 box crops the image to 10x10 pixels, which can't fill more than 10x5 characters
+	options := ConversionOptions{
+		Dimensions: []int{20, 10},
+		Sizing:     Sizing{FitMode: "cover", NoUpscale: true},
+	}
+
+	smallImg, err := resizeImage(gradientImage(100, 10), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := smallImg.Bounds().Size(); size != image.Pt(10, 5) {
+		t.Errorf("resized image is %v, want 10x5", size)
+	}
+}
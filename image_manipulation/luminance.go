@@ -0,0 +1,246 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image_conversions
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Models for computing the brightness of a pixel that decides its character
+var luminanceModels = map[string]bool{
+	"rec601": true,
+	"rec709": true,
+	"lstar":  true,
+	"red":    true,
+	"green":  true,
+	"blue":   true,
+}
+
+// Lookup table converting gamma encoded sRGB values to linear light between 0 and 1
+var srgbToLinearTable [256]float64
+
+func init() {
+	for i := range srgbToLinearTable {
+		v := float64(i) / 255
+
+		if v <= 0.04045 {
+			srgbToLinearTable[i] = v / 12.92
+		} else {
+			srgbToLinearTable[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+}
+
+// Returns true if the passed name is one of the luminance models supported by getLuminance()
+func IsLuminanceModel(name string) bool {
+	return luminanceModels[name]
+}
+
+/*
+Returns the brightness of the passed sRGB color between 0 and 255 according to the passed model.
+
+"rec601" and "rec709" weigh the gamma encoded channels with the coefficients of their standards, while "lstar"
+computes CIE L* from the linear light luminance, which follows perceived lightness most closely. "red", "green"
+and "blue" only use a single channel. An empty or unknown model defaults to "rec601"
+*/
+func getLuminance(r, g, b uint8, model string) uint8 {
+
+	switch model {
+	case "rec709":
+		return uint8(0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b) + 0.5)
+
+	case "lstar":
+		y := 0.2126*srgbToLinearTable[r] + 0.7152*srgbToLinearTable[g] + 0.0722*srgbToLinearTable[b]
+
+		var lightness float64
+		if y > 216.0/24389 {
+			lightness = 116*math.Cbrt(y) - 16
+		} else {
+			lightness = y * 24389 / 27
+		}
+
+		return uint8(lightness*255/100 + 0.5)
+
+	case "red":
+		return r
+
+	case "green":
+		return g
+
+	case "blue":
+		return b
+
+	default:
+		return uint8(0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b) + 0.5)
+	}
+}
+
+// Returns a grayscale version of img according to the passed luminance model, keeping its alpha channel
+func grayscaleImage(img image.Image, model string) *image.NRGBA {
+
+	result := imaging.Clone(img)
+
+	for i := 0; i+3 < len(result.Pix); i += 4 {
+		gray := getLuminance(result.Pix[i], result.Pix[i+1], result.Pix[i+2], model)
+
+		result.Pix[i] = gray
+		result.Pix[i+1] = gray
+		result.Pix[i+2] = gray
+	}
+
+	return result
+}
+
+// Converts a linear light value between 0 and 1 to a gamma encoded sRGB value
+func linearToSrgb(v float64) uint8 {
+
+	if v <= 0 {
+		return 0
+	} else if v >= 1 {
+		return 255
+	}
+
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+
+	return uint8(v*255 + 0.5)
+}
+
+// Source pixel and its weight, contributing to a pixel of a resized image
+type resampleWeight struct {
+	index  int
+	weight float64
+}
+
+// Computes the weights of source pixels contributing to each pixel of a resized axis, in the same way as imaging.Resize()
+func getResampleWeights(dstSize, srcSize int, filter imaging.ResampleFilter) [][]resampleWeight {
+
+	du := float64(srcSize) / float64(dstSize)
+
+	// The filter is stretched over more source pixels when shrinking
+	scale := math.Max(du, 1)
+	radius := math.Ceil(scale * filter.Support)
+
+	weights := make([][]resampleWeight, dstSize)
+
+	for v := 0; v < dstSize; v++ {
+		fu := (float64(v)+0.5)*du - 0.5
+
+		begin := maxInt(0, int(math.Ceil(fu-radius)))
+		end := minInt(srcSize-1, int(math.Floor(fu+radius)))
+
+		var sum float64
+		for u := begin; u <= end; u++ {
+			w := filter.Kernel((float64(u) - fu) / scale)
+			if w != 0 {
+				sum += w
+				weights[v] = append(weights[v], resampleWeight{index: u, weight: w})
+			}
+		}
+
+		if sum == 0 {
+			nearest := minInt(srcSize-1, maxInt(0, int(math.Floor(fu+0.5))))
+			weights[v] = []resampleWeight{{index: nearest, weight: 1}}
+			continue
+		}
+
+		for i := range weights[v] {
+			weights[v][i].weight /= sum
+		}
+	}
+
+	return weights
+}
+
+/*
+Resizes img with the passed filter after converting it to linear light, so that bright and dark pixels are
+blended in proportion to the light they emit rather than their gamma encoded values. Colors are blended with
+their alpha premultiplied. The filter must have a kernel, so imaging.NearestNeighbor isn't supported
+*/
+func resizeLinearLight(img image.Image, width, height int, filter imaging.ResampleFilter) *image.NRGBA {
+
+	src := imaging.Clone(img)
+	srcWidth := src.Bounds().Dx()
+	srcHeight := src.Bounds().Dy()
+
+	// Resize horizontally, then vertically. Source rows are converted to linear light one at a time, so that only
+	// the horizontally resized image, which is as narrow as the result, is kept in floating point
+	columnWeights := getResampleWeights(width, srcWidth, filter)
+	horizontal := make([]float64, width*srcHeight*4)
+
+	// Premultiplied linear light pixels of a source row, 4 values per pixel
+	row := make([]float64, srcWidth*4)
+
+	for y := 0; y < srcHeight; y++ {
+		for x := 0; x < srcWidth; x++ {
+			i := src.PixOffset(x, y)
+			alpha := float64(src.Pix[i+3]) / 255
+
+			row[x*4] = srgbToLinearTable[src.Pix[i]] * alpha
+			row[x*4+1] = srgbToLinearTable[src.Pix[i+1]] * alpha
+			row[x*4+2] = srgbToLinearTable[src.Pix[i+2]] * alpha
+			row[x*4+3] = alpha
+		}
+
+		for x := 0; x < width; x++ {
+			j := (y*width + x) * 4
+
+			for _, w := range columnWeights[x] {
+				k := w.index * 4
+				for c := 0; c < 4; c++ {
+					horizontal[j+c] += row[k+c] * w.weight
+				}
+			}
+		}
+	}
+
+	rowWeights := getResampleWeights(height, srcHeight, filter)
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+
+			var values [4]float64
+			for _, w := range rowWeights[y] {
+				k := (w.index*width + x) * 4
+				for c := 0; c < 4; c++ {
+					values[c] += horizontal[k+c] * w.weight
+				}
+			}
+
+			// Sharp filters can overshoot past the range of alpha
+			alpha := math.Min(values[3], 1)
+			if alpha <= 0 {
+				continue
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = linearToSrgb(values[0] / alpha)
+			dst.Pix[i+1] = linearToSrgb(values[1] / alpha)
+			dst.Pix[i+2] = linearToSrgb(values[2] / alpha)
+			dst.Pix[i+3] = uint8(alpha*255 + 0.5)
+		}
+	}
+
+	return dst
+}
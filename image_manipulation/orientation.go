@@ -0,0 +1,166 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image_conversions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// EXIF tag holding the orientation of an image
+const exifOrientationTag = 0x0112
+
+/*
+Reads the EXIF orientation of JPEG or TIFF data. The returned value is between 1 and 8 as defined by the EXIF
+specification, where 1 means the image is already upright. 1 is also returned if no orientation is found
+*/
+func GetExifOrientation(data []byte) int {
+
+	// TIFF files carry their tags directly
+	if bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")) {
+		return getTiffOrientation(data)
+	}
+
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk through JPEG segments until the APP1 segment holding EXIF data is found
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+
+		marker := data[i+1]
+
+		// Start of scan, image data follows and no more metadata will be found
+		if marker == 0xDA {
+			return 1
+		}
+
+		segmentLength := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		segmentEnd := i + 2 + segmentLength
+		if segmentLength < 2 || segmentEnd > len(data) {
+			return 1
+		}
+
+		segment := data[i+4 : segmentEnd]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return getTiffOrientation(segment[6:])
+		}
+
+		i = segmentEnd
+	}
+
+	return 1
+}
+
+// Reads the orientation tag from the first image file directory of TIFF structured data
+func getTiffOrientation(data []byte) int {
+
+	if len(data) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifdOffset := int(order.Uint32(data[4:8]))
+	if ifdOffset+2 > len(data) {
+		return 1
+	}
+
+	entries := int(order.Uint16(data[ifdOffset : ifdOffset+2]))
+
+	for i := 0; i < entries; i++ {
+		entry := ifdOffset + 2 + i*12
+		if entry+12 > len(data) {
+			return 1
+		}
+
+		if order.Uint16(data[entry:entry+2]) == exifOrientationTag {
+			orientation := int(order.Uint16(data[entry+8 : entry+10]))
+
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+// Transforms img so that it's upright according to the passed EXIF orientation value
+func ApplyExifOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
+
+/*
+Rotates img clockwise by the passed degrees. Multiples of 90 degrees are rotated without any loss, while other
+angles enlarge the image to fit the rotated one and fill the uncovered corners with fillColor
+*/
+func RotateImage(img image.Image, degrees float64, fillColor color.Color) image.Image {
+
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+
+	// imaging rotates counter-clockwise
+	switch degrees {
+	case 0:
+		return img
+	case 90:
+		return imaging.Rotate270(img)
+	case 180:
+		return imaging.Rotate180(img)
+	case 270:
+		return imaging.Rotate90(img)
+	default:
+		return imaging.Rotate(img, 360-degrees, fillColor)
+	}
+}
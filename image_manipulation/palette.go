@@ -0,0 +1,336 @@
+/*
+Copyright © 2021 Zoraiz Hassan <hzoraiz8@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image_conversions
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// Maximum number of pixels sampled from all passed images when generating a palette, to keep global palettes fast
+const maxPaletteSamples = 1 << 20
+
+// Returns true if the passed name is one of the palette generation methods supported by GeneratePalette()
+func IsPaletteMethod(name string) bool {
+	return name == "plan9" || name == "median-cut" || name == "octree"
+}
+
+/*
+Generates a palette of at most numColors colors for the passed images. "median-cut" repeatedly splits the box of
+colors with the widest range at its median, while "octree" merges the least used branches of an octree of colors.
+Any other method returns the fixed palette.Plan9
+*/
+func GeneratePalette(images []image.Image, method string, numColors int) color.Palette {
+
+	if method != "median-cut" && method != "octree" {
+		return palette.Plan9[:numColors]
+	}
+
+	histogram := getColorHistogram(images)
+
+	var result color.Palette
+	if method == "median-cut" {
+		result = medianCutPalette(histogram, numColors)
+	} else {
+		result = octreePalette(histogram, numColors)
+	}
+
+	// A palette can't be empty, even for empty images
+	if len(result) == 0 {
+		result = color.Palette{color.Black}
+	}
+
+	return result
+}
+
+// Distinct color and the number of sampled pixels that have it
+type colorCount struct {
+	rgb   [3]uint8
+	count int
+}
+
+// Counts the colors of the passed images, sampling evenly spaced pixels if they have too many in total
+func getColorHistogram(images []image.Image) []colorCount {
+
+	totalPixels := 0
+	for _, img := range images {
+		totalPixels += img.Bounds().Dx() * img.Bounds().Dy()
+	}
+
+	step := 1
+	if totalPixels > maxPaletteSamples {
+		step = totalPixels/maxPaletteSamples + 1
+	}
+
+	counts := make(map[[3]uint8]int)
+
+	for _, img := range images {
+		src := imaging.Clone(img)
+
+		for i := 0; i+3 < len(src.Pix); i += 4 * step {
+			counts[[3]uint8{src.Pix[i], src.Pix[i+1], src.Pix[i+2]}]++
+		}
+	}
+
+	histogram := make([]colorCount, 0, len(counts))
+	for rgb, count := range counts {
+		histogram = append(histogram, colorCount{rgb, count})
+	}
+
+	return histogram
+}
+
+// Returns the weighted average color of the passed histogram entries
+func averageColor(colors []colorCount) color.Color {
+
+	var sum [3]int
+	var total int
+
+	for _, c := range colors {
+		for k := 0; k < 3; k++ {
+			sum[k] += int(c.rgb[k]) * c.count
+		}
+		total += c.count
+	}
+
+	if total == 0 {
+		return color.Black
+	}
+
+	return color.RGBA{uint8(sum[0] / total), uint8(sum[1] / total), uint8(sum[2] / total), 255}
+}
+
+func medianCutPalette(histogram []colorCount, numColors int) color.Palette {
+
+	if len(histogram) == 0 {
+		return nil
+	}
+
+	boxes := [][]colorCount{histogram}
+
+	for len(boxes) < numColors {
+
+		// Split the box whose widest channel range, weighted by its pixels, is the largest
+		best := -1
+		var bestScore, bestChannel int
+
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+
+			channel, colorRange := getWidestChannel(box)
+
+			pixels := 0
+			for _, c := range box {
+				pixels += c.count
+			}
+
+			if score := colorRange * pixels; score > bestScore {
+				best = i
+				bestScore = score
+				bestChannel = channel
+			}
+		}
+
+		if best == -1 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(a, b int) bool {
+			return box[a].rgb[bestChannel] < box[b].rgb[bestChannel]
+		})
+
+		// Split at the median pixel rather than the median color
+		pixels := 0
+		for _, c := range box {
+			pixels += c.count
+		}
+
+		split, seen := 1, 0
+		for i, c := range box[:len(box)-1] {
+			seen += c.count
+			if seen*2 >= pixels {
+				split = i + 1
+				break
+			}
+		}
+
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	result := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		result[i] = averageColor(box)
+	}
+
+	return result
+}
+
+// Returns the RGB channel with the widest range of values in the passed box, along with that range
+func getWidestChannel(box []colorCount) (int, int) {
+
+	low := [3]int{255, 255, 255}
+	var high [3]int
+
+	for _, c := range box {
+		for k := 0; k < 3; k++ {
+			low[k] = minInt(low[k], int(c.rgb[k]))
+			high[k] = maxInt(high[k], int(c.rgb[k]))
+		}
+	}
+
+	channel := 0
+	for k := 1; k < 3; k++ {
+		if high[k]-low[k] > high[channel]-low[channel] {
+			channel = k
+		}
+	}
+
+	return channel, high[channel] - low[channel]
+}
+
+type octreeNode struct {
+	children [8]*octreeNode
+	sum      [3]int
+	count    int
+	isLeaf   bool
+}
+
+func octreePalette(histogram []colorCount, numColors int) color.Palette {
+
+	root := &octreeNode{}
+
+	// Nodes at each depth that have children, which can be merged into leaves
+	var reducible [8][]*octreeNode
+	reducible[0] = []*octreeNode{root}
+	leaves := 0
+
+	for _, c := range histogram {
+		node := root
+
+		for depth := 0; depth < 8; depth++ {
+			shift := 7 - depth
+			index := int(c.rgb[0]>>shift&1)<<2 | int(c.rgb[1]>>shift&1)<<1 | int(c.rgb[2]>>shift&1)
+
+			if node.children[index] == nil {
+				child := &octreeNode{isLeaf: depth == 7}
+				node.children[index] = child
+
+				if child.isLeaf {
+					leaves++
+				} else {
+					reducible[depth+1] = append(reducible[depth+1], child)
+				}
+			}
+
+			node = node.children[index]
+		}
+
+		for k := 0; k < 3; k++ {
+			node.sum[k] += int(c.rgb[k]) * c.count
+		}
+		node.count += c.count
+	}
+
+	// Merge the deepest nodes into leaves until few enough colors remain, least used ones first
+	for depth := 7; depth >= 0 && leaves > numColors; depth-- {
+
+		nodes := reducible[depth]
+		sort.Slice(nodes, func(a, b int) bool {
+			return subtreeCount(nodes[a]) < subtreeCount(nodes[b])
+		})
+
+		for _, node := range nodes {
+			if leaves <= numColors {
+				break
+			}
+
+			leaves -= mergeChildren(node) - 1
+		}
+	}
+
+	var result color.Palette
+	collectLeafColors(root, &result)
+
+	return result
+}
+
+// Returns the number of pixels counted in the passed node and all of its descendants
+func subtreeCount(node *octreeNode) int {
+	count := node.count
+	for _, child := range node.children {
+		if child != nil {
+			count += subtreeCount(child)
+		}
+	}
+	return count
+}
+
+// Turns the passed node into a leaf holding the colors of all of its descendants, returning how many leaves it replaced
+func mergeChildren(node *octreeNode) int {
+
+	if node.isLeaf {
+		return 1
+	}
+
+	merged := 0
+	for i, child := range node.children {
+		if child == nil {
+			continue
+		}
+
+		merged += mergeChildren(child)
+
+		for k := 0; k < 3; k++ {
+			node.sum[k] += child.sum[k]
+		}
+		node.count += child.count
+		node.children[i] = nil
+	}
+
+	node.isLeaf = true
+
+	return merged
+}
+
+func collectLeafColors(node *octreeNode, result *color.Palette) {
+
+	if node.isLeaf {
+		if node.count > 0 {
+			*result = append(*result, color.RGBA{
+				uint8(node.sum[0] / node.count),
+				uint8(node.sum[1] / node.count),
+				uint8(node.sum[2] / node.count),
+				255,
+			})
+		}
+		return
+	}
+
+	for _, child := range node.children {
+		if child != nil {
+			collectLeafColors(child, result)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"math"
 
 	"github.com/TheZoraiz/ascii-image-converter/aic_package/winsize"
 	"github.com/disintegration/imaging"
@@ -27,20 +28,96 @@ import (
 	"github.com/makeworld-the-better-one/dither/v2"
 )
 
-func ditherImage(img image.Image) image.Image {
+// Error diffusion matrices that can be chosen for dithering
+var errorDiffusionMatrices = map[string]dither.ErrorDiffusionMatrix{
+	"floyd-steinberg":     dither.FloydSteinberg,
+	"atkinson":            dither.Atkinson,
+	"jarvis-judice-ninke": dither.JarvisJudiceNinke,
+	"sierra":              dither.Sierra,
+}
+
+// Sizes of ordered Bayer matrices that can be chosen for dithering
+var bayerMatrixSizes = map[string]uint{
+	"bayer-2x2":   2,
+	"bayer-4x4":   4,
+	"bayer-8x8":   8,
+	"bayer-16x16": 16,
+}
+
+// Filters that can be chosen for resizing images with imaging.Resize()
+var resampleFilters = map[string]imaging.ResampleFilter{
+	"nearest":     imaging.NearestNeighbor,
+	"box":         imaging.Box,
+	"linear":      imaging.Linear,
+	"catmull-rom": imaging.CatmullRom,
+	"lanczos":     imaging.Lanczos,
+}
+
+// Returns true if the passed name is one of the resampling filters supported by resizeImage(), including "area"
+func IsResampleFilter(name string) bool {
+	_, isFilter := resampleFilters[name]
+
+	return isFilter || name == "area"
+}
+
+// Returns true if the passed name is one of the dithering matrices supported by ditherImage()
+func IsDitherMatrix(name string) bool {
+	_, isErrorDiffusion := errorDiffusionMatrices[name]
+	_, isBayer := bayerMatrixSizes[name]
+
+	return isErrorDiffusion || isBayer
+}
 
-	palette := []color.Color{
-		color.Black,
-		color.White,
+/*
+Dithers the passed image to the passed palette using the chosen matrix. An empty matrix name
+defaults to FloydSteinberg.
+
+For braille art, the palette only contains black and white. For ascii art, it contains one gray
+level for each character of the active character table, which reduces banding on gradients.
+*/
+func ditherImage(img image.Image, matrix string, palette []color.Color) (image.Image, error) {
+
+	if matrix == "" {
+		matrix = "floyd-steinberg"
 	}
 
 	d := dither.NewDitherer(palette)
-	d.Matrix = dither.FloydSteinberg
 
-	return d.DitherCopy(img)
+	if edm, ok := errorDiffusionMatrices[matrix]; ok {
+		d.Matrix = edm
+
+	} else if size, ok := bayerMatrixSizes[matrix]; ok {
+		// Bayer offsets are scaled down to the spacing between palette levels, so that
+		// the matrix only dithers between neighbouring levels
+		strength := float32(1)
+		if len(palette) > 2 {
+			strength = 1 / float32(len(palette)-1)
+		}
+		d.Mapper = dither.Bayer(size, size, strength)
+
+	} else {
+		return nil, fmt.Errorf("unknown dither matrix: %v", matrix)
+	}
+
+	return d.DitherCopy(img), nil
 }
 
-func resizeImage(img image.Image, full, isBraille bool, dimensions []int, width, height int) (image.Image, error) {
+// Returns a palette of gray levels where each level falls in the middle of the charDepth
+// range that ConvertToAsciiChars() maps to one character of a table with passed length
+func getGrayLevelPalette(levels int) []color.Color {
+
+	var palette []color.Color
+
+	for i := 0; i < levels; i++ {
+		value := (float64(i) + 0.5) * MAX_VAL / float64(levels)
+		palette = append(palette, color.Gray{Y: uint8(value)})
+	}
+
+	return palette
+}
+
+// Resizes the passed image to the number of pixels sampled for the ascii art of the passed options
+func resizeImage(img image.Image, options ConversionOptions) (image.Image, error) {
 
 	var asciiWidth, asciiHeight int
 	var smallImg image.Image
@@ -49,7 +126,13 @@ func resizeImage(img image.Image, full, isBraille bool, dimensions []int, width,
 	imgHeight := float64(img.Bounds().Dy())
 	aspectRatio := imgWidth / imgHeight
 
-	if full {
+	// Characters are usually about twice as tall as they are wide
+	charAspect := options.CharAspect
+	if charAspect <= 0 {
+		charAspect = 2
+	}
+
+	if options.Full {
 		terminalWidth, _, err := winsize.GetTerminalSize()
 		if err != nil {
 			return nil, err
@@ -57,28 +140,28 @@ func resizeImage(img image.Image, full, isBraille bool, dimensions []int, width,
 
 		asciiWidth = terminalWidth - 1
 		asciiHeight = int(float64(asciiWidth) / aspectRatio)
-		asciiHeight = int(0.5 * float64(asciiHeight))
+		asciiHeight = int(float64(asciiHeight) / charAspect)
 
-	} else if (width != 0 || height != 0) && len(dimensions) == 0 {
+	} else if (options.Width != 0 || options.Height != 0) && len(options.Dimensions) == 0 {
 		// If either width or height is set and dimensions aren't given
 
-		if width != 0 && height == 0 {
+		if options.Width != 0 && options.Height == 0 {
 			// If width is set and height is not set, use width to calculate aspect ratio
 
-			asciiWidth = width
+			asciiWidth = options.Width
 			asciiHeight = int(float64(asciiWidth) / aspectRatio)
-			asciiHeight = int(0.5 * float64(asciiHeight))
+			asciiHeight = int(float64(asciiHeight) / charAspect)
 
 			if asciiHeight == 0 {
 				asciiHeight = 1
 			}
 
-		} else if height != 0 && width == 0 {
+		} else if options.Height != 0 && options.Width == 0 {
 			// If height is set and width is not set, use height to calculate aspect ratio
 
-			asciiHeight = height
+			asciiHeight = options.Height
 			asciiWidth = int(float64(asciiHeight) * aspectRatio)
-			asciiWidth = int(2 * float64(asciiWidth))
+			asciiWidth = int(charAspect * float64(asciiWidth))
 
 			if asciiWidth == 0 {
 				asciiWidth = 1
@@ -88,7 +171,7 @@ func resizeImage(img image.Image, full, isBraille bool, dimensions []int, width,
 			return nil, fmt.Errorf("error: both width and height can't be set. Use dimensions instead")
 		}
 
-	} else if len(dimensions) == 0 {
+	} else if len(options.Dimensions) == 0 {
 		// This condition calculates aspect ratio according to terminal height
 
 		terminalWidth, terminalHeight, err := winsize.GetTerminalSize()
@@ -98,32 +181,344 @@ func resizeImage(img image.Image, full, isBraille bool, dimensions []int, width,
 
 		asciiHeight = terminalHeight - 1
 		asciiWidth = int(float64(asciiHeight) * aspectRatio)
-		asciiWidth = int(2 * float64(asciiWidth))
+		asciiWidth = int(charAspect * float64(asciiWidth))
 
 		// If ascii width exceeds terminal width, change ratio with respect to terminal width
 		if asciiWidth >= terminalWidth {
 			asciiWidth = terminalWidth - 1
 			asciiHeight = int(float64(asciiWidth) / aspectRatio)
-			asciiHeight = int(0.5 * float64(asciiHeight))
+			asciiHeight = int(float64(asciiHeight) / charAspect)
 		}
 
 	} else {
 		// Else, set passed dimensions
 
-		asciiWidth = dimensions[0]
-		asciiHeight = dimensions[1]
+		asciiWidth = options.Dimensions[0]
+		asciiHeight = options.Dimensions[1]
+
+		switch options.Sizing.FitMode {
+		case "contain":
+			// Shrink one side so that the image keeps its aspect ratio inside the box
+			asciiHeight = maxInt(1, int(float64(asciiWidth)/aspectRatio/charAspect))
+
+			if asciiHeight > options.Dimensions[1] {
+				asciiHeight = options.Dimensions[1]
+				asciiWidth = maxInt(1, int(float64(asciiHeight)*aspectRatio*charAspect))
+			}
+
+		case "cover":
+			// Crop the image to the aspect ratio of the box, so that it fills the box without stretching
+			boxAspectRatio := float64(asciiWidth) / (float64(asciiHeight) * charAspect)
+
+			if aspectRatio > boxAspectRatio {
+				img = imaging.CropCenter(img, maxInt(1, int(imgHeight*boxAspectRatio)), int(imgHeight))
+			} else {
+				img = imaging.CropCenter(img, int(imgWidth), maxInt(1, int(imgWidth/boxAspectRatio)))
+			}
+
+			// The size limits apply to the cropped image
+			imgWidth = float64(img.Bounds().Dx())
+			imgHeight = float64(img.Bounds().Dy())
+		}
+	}
+
+	asciiWidth, asciiHeight = limitSize(asciiWidth, asciiHeight, int(imgWidth), int(imgHeight), options.Braille, options.WideChars, options.Sizing)
+
+	// Double-width characters take up two columns each, so only half as many fit in a line
+	if options.WideChars && !options.Braille {
+		asciiWidth /= 2
+
+		if asciiWidth == 0 {
+			asciiWidth = 1
+		}
 	}
 
 	// Because one braille character has 8 dots (4 rows and 2 columns)
-	if isBraille {
+	if options.Braille {
 		asciiWidth *= 2
 		asciiHeight *= 4
 	}
-	smallImg = imaging.Resize(img, asciiWidth, asciiHeight, imaging.Lanczos)
+
+	filter, ok := resampleFilters[options.ResampleFilter]
+	if !ok {
+		filter = imaging.Lanczos
+	}
+
+	if options.ResampleFilter == "area" {
+		smallImg = resizeByAreaAverage(img, asciiWidth, asciiHeight, options.LinearLight)
+	} else if options.LinearLight && options.ResampleFilter != "nearest" {
+		// Nearest neighbor doesn't blend pixels, so linear light makes no difference to it
+		smallImg = resizeLinearLight(img, asciiWidth, asciiHeight, filter)
+	} else {
+		smallImg = imaging.Resize(img, asciiWidth, asciiHeight, filter)
+	}
 
 	return smallImg, nil
 }
 
+/*
+Scales the passed ascii art dimensions down, keeping their ratio, so that they don't exceed the maximum width and
+height of sizing. If sizing.NoUpscale is set, they are also scaled down until the resized image has no more pixels
+on either axis than the original image, accounting for braille dots and double-width characters
+*/
+func limitSize(asciiWidth, asciiHeight, imgWidth, imgHeight int, isBraille, wideChars bool, sizing Sizing) (int, int) {
+
+	scale := 1.0
+
+	if sizing.MaxWidth > 0 && asciiWidth > sizing.MaxWidth {
+		scale = math.Min(scale, float64(sizing.MaxWidth)/float64(asciiWidth))
+	}
+
+	if sizing.MaxHeight > 0 && asciiHeight > sizing.MaxHeight {
+		scale = math.Min(scale, float64(sizing.MaxHeight)/float64(asciiHeight))
+	}
+
+	if sizing.NoUpscale {
+		pixelWidth := float64(asciiWidth)
+		pixelHeight := float64(asciiHeight)
+
+		if isBraille {
+			pixelWidth *= 2
+			pixelHeight *= 4
+		} else if wideChars {
+			pixelWidth /= 2
+		}
+
+		scale = math.Min(scale, float64(imgWidth)/pixelWidth)
+		scale = math.Min(scale, float64(imgHeight)/pixelHeight)
+	}
+
+	if scale >= 1 {
+		return asciiWidth, asciiHeight
+	}
+
+	return maxInt(1, int(float64(asciiWidth)*scale)), maxInt(1, int(float64(asciiHeight)*scale))
+}
+
+/*
+Resizes img by setting each pixel of the result to the mean of the block of source pixels it covers.
+Unlike resampling filters, no pixel outside the block contributes and no ringing is introduced.
+Colors are averaged with their alpha premultiplied, so transparent pixels don't darken their block.
+If linearLight is true, colors are averaged in linear light instead of their gamma encoded values
+*/
+func resizeByAreaAverage(img image.Image, width, height int, linearLight bool) *image.NRGBA {
+
+	src := imaging.Clone(img)
+	srcWidth := src.Bounds().Dx()
+	srcHeight := src.Bounds().Dy()
+
+	decode := func(v uint8) float64 {
+		if linearLight {
+			return srgbToLinearTable[v]
+		}
+		return float64(v) / 255
+	}
+
+	encode := func(v float64) uint8 {
+		if linearLight {
+			return linearToSrgb(v)
+		}
+		return uint8(math.Min(v, 1)*255 + 0.5)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := maxInt(y0+1, (y+1)*srcHeight/height)
+
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := maxInt(x0+1, (x+1)*srcWidth/width)
+
+			var r, g, b, a, count float64
+
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := src.PixOffset(sx, sy)
+					alpha := float64(src.Pix[i+3]) / 255
+
+					r += decode(src.Pix[i]) * alpha
+					g += decode(src.Pix[i+1]) * alpha
+					b += decode(src.Pix[i+2]) * alpha
+					a += alpha
+					count++
+				}
+			}
+
+			if a == 0 {
+				continue
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = encode(r / a)
+			dst.Pix[i+1] = encode(g / a)
+			dst.Pix[i+2] = encode(b / a)
+			dst.Pix[i+3] = uint8(a/count*255 + 0.5)
+		}
+	}
+
+	return dst
+}
+
+// Returns a threshold grid of imgSet's dimensions with the same value for every pixel
+func getUniformThresholds(imgSet [][]AsciiPixel, threshold uint32) [][]uint32 {
+
+	thresholds := make([][]uint32, len(imgSet))
+
+	for i := range imgSet {
+		thresholds[i] = make([]uint32, len(imgSet[i]))
+		for j := range thresholds[i] {
+			thresholds[i][j] = threshold
+		}
+	}
+
+	return thresholds
+}
+
+// Calculates Otsu's threshold, which best separates the charDepth histogram of imgSet into two classes
+func getOtsuThreshold(imgSet [][]AsciiPixel) uint32 {
+
+	var histogram [256]float64
+	var total, sum float64
+
+	for _, row := range imgSet {
+		for _, pixel := range row {
+			// Transparent pixels are blank regardless of the threshold
+			if pixel.transparent {
+				continue
+			}
+
+			histogram[pixel.charDepth]++
+			total++
+			sum += float64(pixel.charDepth)
+		}
+	}
+
+	var (
+		backgroundSum    float64
+		backgroundWeight float64
+		maxVariance      float64
+		threshold        uint32 = 128
+	)
+
+	for i := 0; i < 256; i++ {
+		backgroundWeight += histogram[i]
+		if backgroundWeight == 0 {
+			continue
+		}
+
+		foregroundWeight := total - backgroundWeight
+		if foregroundWeight == 0 {
+			break
+		}
+
+		backgroundSum += float64(i) * histogram[i]
+
+		backgroundMean := backgroundSum / backgroundWeight
+		foregroundMean := (sum - backgroundSum) / foregroundWeight
+
+		// Between-class variance
+		variance := backgroundWeight * foregroundWeight * (backgroundMean - foregroundMean) * (backgroundMean - foregroundMean)
+
+		if variance > maxVariance {
+			maxVariance = variance
+			// Dots are lit for values greater than or equal to the threshold, so the
+			// threshold starts right after the background class
+			threshold = uint32(i + 1)
+		}
+	}
+
+	return threshold
+}
+
+// Minimum standard deviation of a neighborhood for its mean to be used as a local threshold.
+// Flatter neighborhoods fall back to the global Otsu threshold so that noise isn't amplified
+const adaptiveMinDeviation float64 = 8
+
+/*
+Calculates a threshold for every pixel of imgSet from the mean charDepth of its neighborhood.
+The neighborhood is a square window whose size depends on imgSet's dimensions. Summed-area tables
+are used so each window's mean and deviation are computed in constant time
+*/
+func getAdaptiveThresholds(imgSet [][]AsciiPixel) [][]uint32 {
+
+	height := len(imgSet)
+	width := len(imgSet[0])
+
+	radius := height
+	if width < radius {
+		radius = width
+	}
+	radius /= 16
+	if radius < 2 {
+		radius = 2
+	}
+
+	// Summed-area tables of charDepth and its square, with an extra leading row and column of zeros
+	sums := make([][]float64, height+1)
+	squareSums := make([][]float64, height+1)
+	sums[0] = make([]float64, width+1)
+	squareSums[0] = make([]float64, width+1)
+
+	for i := 0; i < height; i++ {
+		sums[i+1] = make([]float64, width+1)
+		squareSums[i+1] = make([]float64, width+1)
+
+		for j := 0; j < width; j++ {
+			value := float64(imgSet[i][j].charDepth)
+
+			sums[i+1][j+1] = value + sums[i][j+1] + sums[i+1][j] - sums[i][j]
+			squareSums[i+1][j+1] = value*value + squareSums[i][j+1] + squareSums[i+1][j] - squareSums[i][j]
+		}
+	}
+
+	globalThreshold := getOtsuThreshold(imgSet)
+
+	thresholds := make([][]uint32, height)
+
+	for i := 0; i < height; i++ {
+		thresholds[i] = make([]uint32, width)
+
+		top := maxInt(i-radius, 0)
+		bottom := minInt(i+radius+1, height)
+
+		for j := 0; j < width; j++ {
+			left := maxInt(j-radius, 0)
+			right := minInt(j+radius+1, width)
+
+			count := float64((bottom - top) * (right - left))
+			sum := sums[bottom][right] - sums[top][right] - sums[bottom][left] + sums[top][left]
+			squareSum := squareSums[bottom][right] - squareSums[top][right] - squareSums[bottom][left] + squareSums[top][left]
+
+			mean := sum / count
+			variance := squareSum/count - mean*mean
+
+			if variance < adaptiveMinDeviation*adaptiveMinDeviation {
+				thresholds[i][j] = globalThreshold
+			} else {
+				thresholds[i][j] = uint32(mean + 0.5)
+			}
+		}
+	}
+
+	return thresholds
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func reverse(imgSet [][]AsciiPixel, flipX, flipY bool) [][]AsciiPixel {
 
 	if flipX {
@@ -164,3 +559,33 @@ func getColoredCharForTerm(r, g, b uint8, char string, background bool) (string,
 
 	return coloredChar, nil
 }
+
+// Same as getColoredCharForTerm(), but sets both foreground and background colors of the character
+func getColoredCharWithBgForTerm(fg, bg [3]uint8, char string) (string, error) {
+	var coloredChar string
+
+	fgColor := gookitColor.RGB(fg[0], fg[1], fg[2])
+	bgColor := gookitColor.RGB(bg[0], bg[1], bg[2], true)
+
+	if termColorLevel == "millions" {
+		colorRenderer := gookitColor.NewRGBStyle(fgColor, bgColor)
+		coloredChar = colorRenderer.Sprintf("%v", char)
+
+	} else if termColorLevel == "hundreds" {
+		colorRenderer := gookitColor.S256(fgColor.C256().Value(), bgColor.C256().Value())
+		coloredChar = colorRenderer.Sprintf("%v", char)
+
+	} else {
+		return "", fmt.Errorf("your terminal supports neither 24-bit nor 8-bit colors. Other coloring options aren't available")
+	}
+
+	return coloredChar, nil
+}
+
+// Composites img over a background of the passed color, removing its transparency
+func compositeOverMatte(img image.Image, matte color.Color) image.Image {
+	b := img.Bounds()
+	background := imaging.New(b.Dx(), b.Dy(), matte)
+
+	return imaging.Overlay(background, img, image.Pt(0, 0), 1)
+}